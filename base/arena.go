@@ -0,0 +1,73 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+// DefaultArenaSlabSize is the number of nodes carved from each slab by trees
+// created by NewWithArena when no slab size is given.
+const DefaultArenaSlabSize = 1024
+
+// arena carves nodes, along with the backing arrays of their items and
+// children, out of large slabs, so that building a tree of n nodes takes a
+// few allocations per slab instead of a few per node.
+//
+// Backing arrays are sized for a full node.  Should an operation need a larger
+// array temporarily, append moves that node's array to the heap as usual.
+type arena struct {
+	slabSize int
+	maxItems int
+	nodes    []node
+	items    items
+	children children
+}
+
+// NewWithArena creates a new B-Tree with the given degree whose nodes are
+// allocated from slabs of slabSize nodes each (DefaultArenaSlabSize if
+// slabSize <= 0).  Trees that are built, queried and thrown away over and over
+// allocate far less this way, at the cost of the unused tail of the last slab
+// and of every node reserving room for a full set of children.
+//
+// Slabs are ordinary Go memory: one is freed by the GC once none of its nodes
+// is referenced any more, which after Clear happens all at once.  The arena
+// belongs to the tree's free list and is shared with the tree's clones.
+func NewWithArena(degree, slabSize int) *BTree {
+	if slabSize <= 0 {
+		slabSize = DefaultArenaSlabSize
+	}
+	f := NewFreeList(DefaultFreeListSize)
+	f.arena = &arena{slabSize: slabSize, maxItems: degree*2 - 1}
+	return NewWithFreeList(degree, f)
+}
+
+// newNode returns the next node of the current slab, starting a new slab if
+// needed.
+func (a *arena) newNode() *node {
+	if len(a.nodes) == 0 {
+		a.nodes = make([]node, a.slabSize)
+		a.items = make(items, a.slabSize*a.maxItems)
+		a.children = make(children, a.slabSize*(a.maxItems+1))
+	}
+	n := &a.nodes[0]
+	a.nodes = a.nodes[1:]
+	n.items = a.items[:0:a.maxItems]
+	a.items = a.items[a.maxItems:]
+	n.children = a.children[: 0 : a.maxItems+1]
+	a.children = a.children[a.maxItems+1:]
+	return n
+}
+
+// release drops the arena's reference to its current slab.
+func (a *arena) release() {
+	a.nodes, a.items, a.children = nil, nil, nil
+}
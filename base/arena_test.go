@@ -0,0 +1,73 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestArena(t *testing.T) {
+	for _, degree := range []int{2, 3, 8} {
+		tr := NewWithArena(degree, 16)
+		for i := 0; i < 3; i++ {
+			for _, v := range perm(1000) {
+				tr.ReplaceOrInsert(v)
+			}
+			clone := tr.Clone()
+			for _, v := range perm(500) {
+				tr.Delete(v)
+			}
+			checkTree(t, tr)
+			if got, want := all(tr), rang(1000)[500:]; !reflect.DeepEqual(got, want) {
+				t.Fatalf("degree %d: mismatch:\n got: %v\nwant: %v", degree, got, want)
+			}
+			if got, want := all(clone), rang(1000); !reflect.DeepEqual(got, want) {
+				t.Fatalf("degree %d: clone mismatch:\n got: %v\nwant: %v", degree, got, want)
+			}
+			tr.Clear(i%2 == 0)
+		}
+	}
+}
+
+func TestArenaAllocs(t *testing.T) {
+	items := perm(1000)
+	build := func(newTree func() *BTree) float64 {
+		return testing.AllocsPerRun(10, func() {
+			tr := newTree()
+			for _, v := range items {
+				tr.ReplaceOrInsert(v)
+			}
+		})
+	}
+	plain := build(func() *BTree { return New(4) })
+	arena := build(func() *BTree { return NewWithArena(4, 0) })
+	if arena*10 > plain {
+		t.Fatalf("arena allocations: got %v, plain tree made %v", arena, plain)
+	}
+}
+
+func BenchmarkArenaBuild(b *testing.B) {
+	items := perm(benchmarkTreeSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tr := NewWithArena(*btreeDegree, 0)
+		for _, v := range items {
+			tr.ReplaceOrInsert(v)
+		}
+		tr.Clear(false)
+	}
+}
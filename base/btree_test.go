@@ -365,6 +365,61 @@ func BenchmarkInsert(b *testing.B) {
 	}
 }
 
+func TestInsertSequential(t *testing.T) {
+	for _, tr := range []*BTree{New(2), New(3), NewMulti(3), NewReverse(4)} {
+		var clones []*BTree
+		for i, v := range rang(1000) {
+			tr.ReplaceOrInsert(v)
+			if tr.multi {
+				tr.ReplaceOrInsert(createItem(i))
+			}
+			if i%97 == 0 {
+				clones = append(clones, tr.Clone())
+				// Reading the digest caches it for the appends to drop.
+				var want uint64
+				tr.walk(func(i *Item) bool {
+					want += itemDigest(i)
+					return true
+				})
+				if got := tr.RangeDigest(nil, nil); got != want {
+					t.Fatalf("after %d: digest %x, want %x", i, got, want)
+				}
+			}
+			if tr.last.Key != v.Key {
+				t.Fatalf("last %v after adding %v", tr.last, v)
+			}
+		}
+		checkTree(t, tr)
+		if tr.Len() != 1000 && tr.Len() != 2000 {
+			t.Fatalf("len %d", tr.Len())
+		}
+		for i, c := range clones {
+			checkTree(t, c)
+			if c.Len() != (i*97+1)*tr.Len()/1000 {
+				t.Fatalf("clone %d: len %d", i, c.Len())
+			}
+		}
+	}
+}
+
+// BenchmarkInsertSequential inserts increasing keys, as time series do.
+func BenchmarkInsertSequential(b *testing.B) {
+	b.StopTimer()
+	insertP := rang(benchmarkTreeSize)
+	b.StartTimer()
+	i := 0
+	for i < b.N {
+		tr := New(*btreeDegree)
+		for _, item := range insertP {
+			tr.ReplaceOrInsert(item)
+			i++
+			if i >= b.N {
+				return
+			}
+		}
+	}
+}
+
 func BenchmarkSeek(b *testing.B) {
 	b.StopTimer()
 	size := 100000
@@ -709,6 +764,34 @@ func TestCloneConcurrentOperations(t *testing.T) {
 	}
 }
 
+func TestCloneDeep(t *testing.T) {
+	tr := New(*btreeDegree)
+	for _, v := range perm(1000) {
+		tr.ReplaceOrInsert(v)
+	}
+	clone := tr.CloneDeep()
+	checkTree(t, clone)
+	// Neither tree shares any nodes, so both still own all of theirs.
+	for _, b := range []*BTree{tr, clone} {
+		if m := b.MemoryUsage(); m.Shared != 0 {
+			t.Fatalf("deep clone shares %d bytes", m.Shared)
+		}
+	}
+	if got, want := TotalMemoryUsage(tr, clone), tr.Stats().Bytes+clone.Stats().Bytes; got != want {
+		t.Fatalf("total memory %d, want %d", got, want)
+	}
+	for _, item := range rang(500) {
+		clone.Delete(item)
+	}
+	tr.ReplaceOrInsert(createItem(1000))
+	if got, want := all(tr), rang(1001); !reflect.DeepEqual(got, want) {
+		t.Fatalf("original mismatch:\n got: %v\nwant: %v", got, want)
+	}
+	if got, want := all(clone), rang(1000)[500:]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("clone mismatch:\n got: %v\nwant: %v", got, want)
+	}
+}
+
 func BenchmarkDeleteAndRestore(b *testing.B) {
 	items := perm(16392)
 	b.ResetTimer()
@@ -789,3 +872,730 @@ func BenchmarkDeleteAndRestore(b *testing.B) {
 		}
 	})
 }
+
+func TestUpsert(t *testing.T) {
+	tr := New(3)
+	count := func(existing, new *Item) *Item {
+		return &Item{Key: existing.Key, Payload: existing.Payload.(int) + new.Payload.(int)}
+	}
+	for i := 0; i < 10; i++ {
+		for _, v := range perm(100) {
+			v.Payload = 1
+			if out := tr.Upsert(v, count); (out == nil) != (i == 0) {
+				t.Fatalf("round %d: upsert of %v returned %v", i, v.Key, out)
+			}
+		}
+	}
+	if tr.Len() != 100 {
+		t.Fatalf("len: want 100, got %d", tr.Len())
+	}
+	tr.Ascend(func(item *Item) bool {
+		if item.Payload.(int) != 10 {
+			t.Fatalf("item %v: want count 10, got %v", item.Key, item.Payload)
+		}
+		return true
+	})
+}
+
+func TestSetGetValue(t *testing.T) {
+	tr := New(*btreeDegree)
+	for _, v := range perm(100) {
+		if old := tr.Set(v.Key, int(v.Key)*2); old != nil {
+			t.Fatalf("set %v replaced %v", v.Key, old)
+		}
+	}
+	if old := tr.Set(KeyType(7), "seven"); old == nil || old.Payload != 14 {
+		t.Fatalf("set 7: replaced %v, want payload 14", old)
+	}
+	for i := 0; i < 100; i++ {
+		value, ok := tr.GetValue(KeyType(i))
+		want := interface{}(i * 2)
+		if i == 7 {
+			want = "seven"
+		}
+		if !ok || value != want {
+			t.Fatalf("get %d: got %v, %v, want %v", i, value, ok, want)
+		}
+	}
+	if value, ok := tr.GetValue(KeyType(100)); ok || value != nil {
+		t.Fatalf("get 100: got %v, %v", value, ok)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	tr := New(3)
+	for i := 0; i < 10; i++ {
+		for _, v := range perm(100) {
+			if got, want := tr.Add(v, int64(v.Key)), int64(v.Key)*int64(i+1); got != want {
+				t.Fatalf("round %d: add to %v gave %d, want %d", i, v.Key, got, want)
+			}
+		}
+	}
+	clone := tr.Clone()
+	tr.Add(createItem(5), -50)
+	if v, _ := tr.GetValue(5); v != int64(0) {
+		t.Fatalf("got %v, want 0", v)
+	}
+	if v, _ := clone.GetValue(5); v != int64(50) {
+		t.Fatalf("add leaked into clone: got %v", v)
+	}
+	tr.Set(1000, nil)
+	if got := tr.AddFloat(createItem(1000), 0.5); got != 0.5 {
+		t.Fatalf("add to nil payload: got %v", got)
+	}
+	if got := tr.AddFloat(createItem(1000), 0.25); got != 0.75 {
+		t.Fatalf("add float: got %v", got)
+	}
+	if tr.Len() != 101 {
+		t.Fatalf("len: want 101, got %d", tr.Len())
+	}
+
+	multi := NewMulti(3)
+	for _, add := range []func(){
+		func() { multi.Add(createItem(1), 1) },
+		func() { multi.AddFloat(createItem(1), 1) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("add to a multi tree did not panic")
+				}
+			}()
+			add()
+		}()
+	}
+	if multi.Len() != 0 {
+		t.Fatalf("multi len: want 0, got %d", multi.Len())
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	tr := New(3)
+	if tr.Update(createItem(1), func(*Item) {}) {
+		t.Fatal("updated item of empty tree")
+	}
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(v)
+	}
+	clone := tr.Clone()
+	for _, v := range perm(100) {
+		if !tr.Update(v, func(i *Item) { i.Payload = int(i.Key) * 2 }) {
+			t.Fatalf("item %v not found", v)
+		}
+	}
+	if tr.Update(createItem(100), func(*Item) {}) {
+		t.Fatal("updated missing item")
+	}
+	checkTree(t, tr)
+	for i := 0; i < 100; i++ {
+		if v, _ := tr.GetValue(KeyType(i)); v != i*2 {
+			t.Fatalf("item %d: got %v", i, v)
+		}
+	}
+	if tr.Min().Payload != 0 || tr.Max().Payload != 198 {
+		t.Fatalf("stale ends: %v, %v", tr.Min(), tr.Max())
+	}
+	if got := all(clone); !reflect.DeepEqual(got, rang(100)) {
+		t.Fatal("clone was modified")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("changing the key did not panic")
+		}
+	}()
+	tr.Update(createItem(5), func(i *Item) { i.Key++ })
+}
+
+// keysOf returns the keys of the items the given iteration visits.
+func keysOf(iterate func(ItemIterator)) (out []int) {
+	iterate(func(i *Item) bool {
+		out = append(out, int(i.Key))
+		return true
+	})
+	return
+}
+
+// seq returns the integers from a to b inclusive, counting down if b < a.
+func seq(a, b int) (out []int) {
+	step := 1
+	if b < a {
+		step = -1
+	}
+	for i := a; i != b+step; i += step {
+		out = append(out, i)
+	}
+	return
+}
+
+func TestReverse(t *testing.T) {
+	tr := NewReverse(3)
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(v)
+	}
+	checkTree(t, tr)
+	for _, test := range []struct {
+		name    string
+		iterate func(ItemIterator)
+		want    []int
+	}{
+		{"ascend", tr.Ascend, seq(99, 0)},
+		{"descend", tr.Descend, seq(0, 99)},
+		{"ascend range", func(i ItemIterator) { tr.AscendRange(createItem(60), createItem(50), i) }, seq(60, 51)},
+		{"ascend less than", func(i ItemIterator) { tr.AscendLessThan(createItem(90), i) }, seq(99, 91)},
+		{"ascend greater or equal", func(i ItemIterator) { tr.AscendGreaterOrEqual(createItem(10), i) }, seq(10, 0)},
+		{"descend range", func(i ItemIterator) { tr.DescendRange(createItem(50), createItem(60), i) }, seq(50, 59)},
+		{"descend less or equal", func(i ItemIterator) { tr.DescendLessOrEqual(createItem(90), i) }, seq(90, 99)},
+		{"descend greater than", func(i ItemIterator) { tr.DescendGreaterThan(createItem(5), i) }, seq(0, 4)},
+	} {
+		if got := keysOf(test.iterate); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+	var keys []int
+	tr.AscendKeys(func(k KeyType) bool {
+		keys = append(keys, int(k))
+		return true
+	})
+	if !reflect.DeepEqual(keys, seq(99, 0)) {
+		t.Errorf("ascend keys: got %v", keys)
+	}
+	if tr.Min().Key != 99 || tr.Max().Key != 0 {
+		t.Errorf("min %v, max %v", tr.Min(), tr.Max())
+	}
+	for _, test := range []struct {
+		lo, hi *Item
+		want   int
+	}{
+		{createItem(60), createItem(50), 10},
+		{nil, createItem(90), 9},
+		{createItem(10), nil, 11},
+		{createItem(50), createItem(60), 0},
+		{createItem(200), createItem(-1), 100},
+		{createItem(60), createItem(99), 0},
+	} {
+		if got := tr.CountRange(test.lo, test.hi); got != test.want {
+			t.Errorf("count [%v, %v): got %d, want %d", test.lo, test.hi, got, test.want)
+		}
+	}
+	if got, want := tr.FirstMatch(func(i *Item) bool { return i.Key <= 30 }), createItem(30); !reflect.DeepEqual(got, want) {
+		t.Errorf("first match: got %v, want %v", got, want)
+	}
+	fwd := New(3)
+	fwd.InsertBatch(rang(100))
+	if tr.RangeDigest(createItem(60), createItem(50)) != fwd.RangeDigest(createItem(51), createItem(61)) {
+		t.Error("range digest mismatch")
+	}
+
+	left, right := tr.Split(createItem(50))
+	if got := keysOf(left.Ascend); !reflect.DeepEqual(got, seq(99, 51)) {
+		t.Errorf("split left: got %v", got)
+	}
+	if got := keysOf(right.Ascend); !reflect.DeepEqual(got, seq(50, 0)) {
+		t.Errorf("split right: got %v", got)
+	}
+	if left, right := tr.Split(createItem(100)); left.Len() != 0 || right.Len() != 100 {
+		t.Errorf("split past the end: %d, %d", left.Len(), right.Len())
+	}
+
+	clone := tr.Clone()
+	if got := tr.DeleteRange(createItem(60), createItem(50)); got != 10 {
+		t.Errorf("delete range removed %d, want 10", got)
+	}
+	if tr.DeleteMin().Key != 99 || tr.DeleteMax().Key != 0 {
+		t.Error("delete min/max removed the wrong items")
+	}
+	checkTree(t, tr)
+	if got, want := keysOf(tr.Ascend), append(seq(98, 61), seq(50, 1)...); !reflect.DeepEqual(got, want) {
+		t.Errorf("after deletes: got %v, want %v", got, want)
+	}
+	if got := keysOf(clone.Ascend); !reflect.DeepEqual(got, seq(99, 0)) {
+		t.Errorf("clone: got %v", got)
+	}
+
+	even := NewReverse(2)
+	for i := 0; i < 100; i += 2 {
+		even.ReplaceOrInsert(createItem(i))
+	}
+	for _, test := range []struct {
+		name string
+		got  *Item
+		want int
+	}{
+		{"floor", even.Floor(createItem(51)), 52},
+		{"ceiling", even.Ceiling(createItem(51)), 50},
+		{"next", even.Next(createItem(50)), 48},
+		{"prev", even.Prev(createItem(50)), 52},
+	} {
+		if test.got == nil || int(test.got.Key) != test.want {
+			t.Errorf("%s: got %v, want %d", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestMinMaxCached(t *testing.T) {
+	tr := New(2)
+	for i, v := range perm(200) {
+		tr.ReplaceOrInsert(v)
+		if i%2 == 1 {
+			tr.Delete(createItem(rand.Intn(200)))
+		}
+		checkTree(t, tr)
+	}
+	// Replacing an end item caches its replacement.
+	last := tr.Max()
+	repl := &Item{Key: last.Key}
+	tr.ReplaceOrInsert(repl)
+	if tr.Max() != repl {
+		t.Fatal("replaced max item still cached")
+	}
+	for tr.Len() > 0 {
+		tr.DeleteMin()
+		tr.DeleteMax()
+		checkTree(t, tr)
+	}
+}
+
+func BenchmarkMin(b *testing.B) {
+	tr := New(*btreeDegree)
+	for _, item := range perm(benchmarkTreeSize) {
+		tr.ReplaceOrInsert(item)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tr.Min()
+	}
+}
+
+func TestFreeListStats(t *testing.T) {
+	fl := NewFreeList(4)
+	tr := NewWithFreeList(2, fl)
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(v)
+	}
+	stats := fl.Stats()
+	if stats.Hits != 0 || stats.Misses == 0 || stats.Size != 0 || stats.MaxSize != 4 {
+		t.Fatalf("after inserts: %+v", stats)
+	}
+	tr.Clear(true)
+	stats = fl.Stats()
+	if stats.Size != 4 || stats.HighWater != 4 {
+		t.Fatalf("after clear: %+v", stats)
+	}
+	fl.SetMaxSize(2)
+	if stats = fl.Stats(); stats.Size != 2 || stats.MaxSize != 2 {
+		t.Fatalf("after shrinking: %+v", stats)
+	}
+	fl.SetMaxSize(64)
+	tr.ReplaceOrInsert(createItem(1))
+	if stats = fl.Stats(); stats.Hits != 1 || stats.Size != 1 || stats.MaxSize != 64 {
+		t.Fatalf("after growing: %+v", stats)
+	}
+}
+
+func TestNewWithPool(t *testing.T) {
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				tr := NewWithPool(2)
+				for _, v := range perm(200) {
+					tr.ReplaceOrInsert(v)
+				}
+				for _, v := range perm(100) {
+					tr.Delete(v)
+				}
+				if got, want := all(tr), rang(200)[100:]; !reflect.DeepEqual(got, want) {
+					t.Errorf("mismatch:\n got: %v\nwant: %v", got, want)
+					return
+				}
+				tr.Clear(true)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkNewWithPool(b *testing.B) {
+	items := perm(1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tr := NewWithPool(*btreeDegree)
+		for _, v := range items {
+			tr.ReplaceOrInsert(v)
+		}
+		tr.Clear(true)
+	}
+}
+
+func TestClearFunc(t *testing.T) {
+	tr := New(3)
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(v)
+	}
+	clone := tr.Clone()
+	var got []*Item
+	tr.ClearFunc(func(item *Item) {
+		got = append(got, item)
+	})
+	if want := rang(100); !reflect.DeepEqual(got, want) {
+		t.Fatalf("mismatch:\n got: %v\nwant: %v", got, want)
+	}
+	if tr.Len() != 0 || tr.Min() != nil {
+		t.Fatalf("tree not empty after ClearFunc")
+	}
+	if got := all(clone); !reflect.DeepEqual(got, rang(100)) {
+		t.Fatalf("clone was modified")
+	}
+}
+
+func TestBetween(t *testing.T) {
+	tr, rev, multi := New(3), NewReverse(3), NewMulti(3)
+	for _, v := range perm(20) {
+		tr.ReplaceOrInsert(v)
+		rev.ReplaceOrInsert(v)
+		multi.ReplaceOrInsert(v)
+		multi.ReplaceOrInsert(createItem(int(v.Key)))
+	}
+	lo, hi := createItem(5), createItem(10)
+	for _, test := range []struct {
+		name    string
+		iterate func(ItemIterator)
+		want    []int
+	}{
+		{"[]", func(i ItemIterator) { tr.AscendBetween(lo, hi, true, true, i) }, seq(5, 10)},
+		{"()", func(i ItemIterator) { tr.AscendBetween(lo, hi, false, false, i) }, seq(6, 9)},
+		{"[)", func(i ItemIterator) { tr.AscendBetween(lo, hi, true, false, i) }, seq(5, 9)},
+		{"(]", func(i ItemIterator) { tr.AscendBetween(lo, hi, false, true, i) }, seq(6, 10)},
+		{"open", func(i ItemIterator) { tr.AscendBetween(nil, hi, false, true, i) }, seq(0, 10)},
+		{"desc []", func(i ItemIterator) { tr.DescendBetween(lo, hi, true, true, i) }, seq(10, 5)},
+		{"desc ()", func(i ItemIterator) { tr.DescendBetween(lo, hi, false, false, i) }, seq(9, 6)},
+		{"desc open", func(i ItemIterator) { tr.DescendBetween(lo, nil, false, false, i) }, seq(19, 6)},
+		{"reverse", func(i ItemIterator) { rev.AscendBetween(hi, lo, false, true, i) }, seq(9, 5)},
+		{"reverse desc", func(i ItemIterator) { rev.DescendBetween(hi, lo, false, true, i) }, seq(5, 9)},
+		{"multi ()", func(i ItemIterator) { multi.AscendBetween(createItem(5), createItem(7), false, false, i) }, []int{6, 6}},
+		{"multi desc []", func(i ItemIterator) { multi.DescendBetween(createItem(5), createItem(6), true, true, i) }, []int{6, 6, 5, 5}},
+	} {
+		if got := keysOf(test.iterate); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestAscendKeys(t *testing.T) {
+	tr := New(3)
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(v)
+	}
+	var got []KeyType
+	tr.AscendKeys(func(key KeyType) bool {
+		got = append(got, key)
+		return key < 49
+	})
+	for i, key := range got {
+		if key != KeyType(i) {
+			t.Fatalf("ascend: got %v at %d", key, i)
+		}
+	}
+	if len(got) != 50 {
+		t.Fatalf("ascend: got %d keys, want 50", len(got))
+	}
+	got = got[:0]
+	tr.DescendKeys(func(key KeyType) bool {
+		got = append(got, key)
+		return true
+	})
+	for i, key := range got {
+		if key != KeyType(99-i) {
+			t.Fatalf("descend: got %v at %d", key, i)
+		}
+	}
+	if len(got) != 100 {
+		t.Fatalf("descend: got %d keys, want 100", len(got))
+	}
+}
+
+func TestUnsafeAscendBatches(t *testing.T) {
+	for _, tr := range []*BTree{New(3), NewReverse(4)} {
+		for _, v := range perm(100) {
+			tr.ReplaceOrInsert(v)
+		}
+		var got []*Item
+		batches := 0
+		tr.UnsafeAscendBatches(func(items []*Item) bool {
+			if len(items) == 0 || len(items) != cap(items) {
+				t.Fatalf("batch of length %d, capacity %d", len(items), cap(items))
+			}
+			batches++
+			got = append(got, items...)
+			return len(got) < 50
+		})
+		if len(got) < 50 || batches >= len(got) {
+			t.Fatalf("%d items in %d batches", len(got), batches)
+		}
+		if want := rang(100)[:len(got)]; !reflect.DeepEqual(got, want) {
+			t.Fatalf("mismatch:\n got: %v\nwant: %v", got, want)
+		}
+	}
+}
+
+func TestChunks(t *testing.T) {
+	for _, tr := range []*BTree{New(3), NewReverse(4)} {
+		for _, v := range perm(100) {
+			tr.ReplaceOrInsert(v)
+		}
+		for _, test := range []struct {
+			chunks  func(func([]*Item) bool)
+			iterate func(ItemIterator)
+		}{
+			{tr.AscendChunks, tr.Ascend},
+			{tr.DescendChunks, tr.Descend},
+		} {
+			var got []*Item
+			chunks := 0
+			test.chunks(func(items []*Item) bool {
+				chunks++
+				got = append(got, items...)
+				items[0] = nil // chunks are copies
+				return len(got) < 50
+			})
+			if len(got) < 50 || chunks >= len(got) {
+				t.Fatalf("%d items in %d chunks", len(got), chunks)
+			}
+			var want []*Item
+			test.iterate(func(i *Item) bool {
+				want = append(want, i)
+				return len(want) < len(got)
+			})
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("mismatch:\n got: %v\nwant: %v", got, want)
+			}
+		}
+		checkTree(t, tr)
+	}
+}
+
+func BenchmarkAscendKeys(b *testing.B) {
+	tr := New(*btreeDegree)
+	for _, v := range perm(benchmarkTreeSize) {
+		v.Payload = make([]byte, 256)
+		tr.ReplaceOrInsert(v)
+	}
+	b.ResetTimer()
+	b.Run(`Ascend`, func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var sum KeyType
+			tr.Ascend(func(item *Item) bool {
+				sum += item.Key
+				return true
+			})
+		}
+	})
+	b.Run(`AscendKeys`, func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var sum KeyType
+			tr.AscendKeys(func(key KeyType) bool {
+				sum += key
+				return true
+			})
+		}
+	})
+	b.Run(`AscendChunks`, func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var sum KeyType
+			tr.AscendChunks(func(items []*Item) bool {
+				for _, item := range items {
+					sum += item.Key
+				}
+				return true
+			})
+		}
+	})
+	b.Run(`UnsafeAscendBatches`, func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var sum KeyType
+			tr.UnsafeAscendBatches(func(items []*Item) bool {
+				for _, item := range items {
+					sum += item.Key
+				}
+				return true
+			})
+		}
+	})
+}
+
+func TestFloorCeiling(t *testing.T) {
+	tr := New(2)
+	if tr.Floor(createItem(1)) != nil || tr.Ceiling(createItem(1)) != nil {
+		t.Fatalf("empty tree returned an item")
+	}
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(createItem(int(v.Key) * 2))
+	}
+	for i := -1; i <= 200; i++ {
+		var floor, ceiling *Item
+		if i >= 0 {
+			floor = createItem(i - i%2)
+			if i > 198 {
+				floor = createItem(198)
+			}
+		}
+		if i <= 198 {
+			ceiling = createItem(i + i%2)
+			if i < 0 {
+				ceiling = createItem(0)
+			}
+		}
+		if got := tr.Floor(createItem(i)); !reflect.DeepEqual(got, floor) {
+			t.Fatalf("floor(%d): got %v, want %v", i, got, floor)
+		}
+		if got := tr.Ceiling(createItem(i)); !reflect.DeepEqual(got, ceiling) {
+			t.Fatalf("ceiling(%d): got %v, want %v", i, got, ceiling)
+		}
+	}
+}
+
+func TestNextPrev(t *testing.T) {
+	tr := New(2)
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(v)
+	}
+	var got []*Item
+	for item := tr.Min(); item != nil; item = tr.Next(item) {
+		got = append(got, item)
+	}
+	if want := rang(100); !reflect.DeepEqual(got, want) {
+		t.Fatalf("next:\n got: %v\nwant: %v", got, want)
+	}
+	got = got[:0]
+	for item := tr.Max(); item != nil; item = tr.Prev(item) {
+		got = append(got, item)
+	}
+	if want := rangrev(100); !reflect.DeepEqual(got, want) {
+		t.Fatalf("prev:\n got: %v\nwant: %v", got, want)
+	}
+	if got := tr.Next(createItem(-5)); got.Key != 0 {
+		t.Fatalf("next(-5): got %v", got)
+	}
+	if got := tr.Prev(createItem(500)); got.Key != 99 {
+		t.Fatalf("prev(500): got %v", got)
+	}
+}
+
+func TestFirstMatch(t *testing.T) {
+	tr := New(2)
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(v)
+	}
+	for i := 0; i <= 101; i++ {
+		calls := 0
+		got := tr.FirstMatch(func(item *Item) bool {
+			calls++
+			return int(item.Key)*int(item.Key) >= i*i
+		})
+		var want *Item
+		if i < 100 {
+			want = createItem(i)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("first match %d: got %v, want %v", i, got, want)
+		}
+		if calls > 30 {
+			t.Fatalf("first match %d: %d calls to pred", i, calls)
+		}
+	}
+}
+
+func TestHas(t *testing.T) {
+	tr := New(3)
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(createItem(int(v.Key) * 2))
+	}
+	for i := -1; i < 201; i++ {
+		if got, want := tr.Has(createItem(i)), i >= 0 && i < 200 && i%2 == 0; got != want {
+			t.Fatalf("has(%d): got %v, want %v", i, got, want)
+		}
+	}
+	key := createItem(42)
+	if allocs := testing.AllocsPerRun(100, func() { tr.Has(key) }); allocs != 0 {
+		t.Fatalf("has allocated %v times", allocs)
+	}
+}
+
+func BenchmarkHas(b *testing.B) {
+	tr := New(*btreeDegree)
+	for _, v := range perm(benchmarkTreeSize) {
+		tr.ReplaceOrInsert(v)
+	}
+	keys := perm(benchmarkTreeSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tr.Has(keys[i%benchmarkTreeSize])
+	}
+}
+
+func TestCountRange(t *testing.T) {
+	for _, degree := range []int{2, 3, 8} {
+		tr := New(degree)
+		for _, v := range perm(300) {
+			tr.ReplaceOrInsert(v)
+		}
+		for i := 0; i < 1000; i++ {
+			lo, hi := rand.Intn(320)-10, rand.Intn(320)-10
+			want := 0
+			tr.AscendRange(createItem(lo), createItem(hi), func(*Item) bool {
+				want++
+				return true
+			})
+			if got := tr.CountRange(createItem(lo), createItem(hi)); got != want {
+				t.Fatalf("degree %d: count [%d, %d): got %d, want %d", degree, lo, hi, got, want)
+			}
+		}
+		if got := tr.CountRange(nil, createItem(100)); got != 100 {
+			t.Fatalf("degree %d: count [, 100): got %d", degree, got)
+		}
+		if got := tr.CountRange(createItem(100), nil); got != 200 {
+			t.Fatalf("degree %d: count [100, ): got %d", degree, got)
+		}
+		if got := tr.CountRange(nil, nil); got != 300 {
+			t.Fatalf("degree %d: count all: got %d", degree, got)
+		}
+	}
+}
+
+func TestClearIncremental(t *testing.T) {
+	fl := NewFreeList(1000)
+	tr := NewWithFreeList(2, fl)
+	for _, v := range perm(1000) {
+		tr.ReplaceOrInsert(v)
+	}
+	clone := tr.Clone()
+	tr.ReplaceOrInsert(createItem(1000))
+	nodes := tr.Stats().Nodes
+	r := tr.ClearIncremental(10)
+	if tr.Len() != 0 || tr.Min() != nil {
+		t.Fatalf("tree not empty after ClearIncremental")
+	}
+	// The tree is usable while nodes are being released.
+	tr.ReplaceOrInsert(createItem(5))
+	steps := 0
+	for r.Next() {
+		steps++
+	}
+	if steps == 0 || steps > nodes/10 {
+		t.Fatalf("released %d nodes in %d steps", nodes, steps)
+	}
+	// Only the nodes copied by the insert after cloning were owned by tr.
+	if size := fl.Stats().Size; size == 0 || size >= nodes {
+		t.Fatalf("freelist holds %d of %d nodes", size, nodes)
+	}
+	if got := all(clone); !reflect.DeepEqual(got, rang(1000)) {
+		t.Fatalf("clone was modified")
+	}
+	if got := all(tr); len(got) != 1 || got[0].Key != 5 {
+		t.Fatalf("tree holds %v", got)
+	}
+}
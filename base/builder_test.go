@@ -0,0 +1,74 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	for _, degree := range []int{2, 3, 4, 8} {
+		for size := 0; size < 300; size++ {
+			b := NewBuilder(degree)
+			for _, v := range rang(size) {
+				b.Append(v)
+			}
+			tr := b.Finish()
+			checkTree(t, tr)
+			if got := all(tr); !reflect.DeepEqual(got, rang(size)) {
+				t.Fatalf("degree %d, size %d: mismatch:\n got: %v\nwant: %v", degree, size, got, rang(size))
+			}
+			if size == 0 {
+				continue
+			}
+			tr.ReplaceOrInsert(createItem(-1))
+			tr.Delete(createItem(size / 2))
+			checkTree(t, tr)
+		}
+	}
+	b := NewBuilder(2)
+	b.Append(createItem(1))
+	defer func() {
+		if recover() == nil {
+			t.Fatal("appending out of order did not panic")
+		}
+	}()
+	b.Append(createItem(1))
+}
+
+func TestBuilderPacking(t *testing.T) {
+	b := NewBuilder(4)
+	for _, v := range rang(10000) {
+		b.Append(v)
+	}
+	tr := b.Finish()
+	// All but a few nodes are full, which random inserts never achieve.
+	if s := tr.Stats(); s.Nodes > 10000/7+20 {
+		t.Fatalf("%d nodes for 10000 items", s.Nodes)
+	}
+}
+
+func BenchmarkBuilder(b *testing.B) {
+	items := rang(benchmarkTreeSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bl := NewBuilder(*btreeDegree)
+		for _, item := range items {
+			bl.Append(item)
+		}
+		bl.Finish()
+	}
+}
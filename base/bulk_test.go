@@ -0,0 +1,254 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	for _, degree := range []int{2, 3, 4, 7} {
+		for size := 0; size < 1500; size += 1 + size/10 {
+			tr := New(degree)
+			tr.rebuild(rang(size))
+			checkTree(t, tr)
+			if got, want := all(tr), rang(size); !reflect.DeepEqual(got, want) {
+				t.Fatalf("degree %d, size %d: mismatch:\n got: %v\nwant: %v", degree, size, got, want)
+			}
+		}
+	}
+}
+
+func TestInsertBatch(t *testing.T) {
+	for _, degree := range []int{2, 3, 8} {
+		for _, batchSize := range []int{0, 1, 10, 100, 1000} {
+			tr, want := New(degree), New(degree)
+			for _, v := range perm(500) {
+				if rand.Intn(2) == 0 {
+					tr.ReplaceOrInsert(v)
+					want.ReplaceOrInsert(v)
+				}
+			}
+			clone := tr.Clone()
+			cloneWant := all(tr)
+			var batch []*Item
+			for i := 0; i < batchSize; i++ {
+				batch = append(batch, &Item{Key: KeyType(rand.Intn(1000)), Payload: i})
+			}
+			before := want.Len()
+			for _, item := range batch {
+				want.ReplaceOrInsert(item)
+			}
+			if added := tr.InsertBatch(batch); added != want.Len()-before {
+				t.Fatalf("degree %d, batch %d: added %d, want %d", degree, batchSize, added, want.Len()-before)
+			}
+			checkTree(t, tr)
+			if got, want := all(tr), all(want); !reflect.DeepEqual(got, want) {
+				t.Fatalf("degree %d, batch %d: mismatch:\n got: %v\nwant: %v", degree, batchSize, got, want)
+			}
+			if got := all(clone); !reflect.DeepEqual(got, cloneWant) {
+				t.Fatalf("degree %d, batch %d: clone was modified", degree, batchSize)
+			}
+		}
+	}
+}
+
+func TestInsertBatchMulti(t *testing.T) {
+	tr, want := NewMulti(3), NewMulti(3)
+	for i := 0; i < 300; i++ {
+		item := &Item{Key: KeyType(rand.Intn(50)), Payload: i}
+		tr.ReplaceOrInsert(item)
+		want.ReplaceOrInsert(item)
+	}
+	var batch []*Item
+	for i := 0; i < 300; i++ {
+		item := &Item{Key: KeyType(rand.Intn(50)), Payload: 300 + i}
+		batch = append(batch, item)
+		want.ReplaceOrInsert(item)
+	}
+	if added := tr.InsertBatch(batch); added != 300 {
+		t.Fatalf("added %d, want 300", added)
+	}
+	checkTree(t, tr)
+	if got, want := all(tr), all(want); !reflect.DeepEqual(got, want) {
+		t.Fatalf("mismatch:\n got: %v\nwant: %v", got, want)
+	}
+}
+
+func BenchmarkInsertBatch(b *testing.B) {
+	insertP := perm(benchmarkTreeSize)
+	b.Run(`InsertBatch`, func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			tr := New(*btreeDegree)
+			tr.InsertBatch(insertP)
+		}
+	})
+	b.Run(`InsertBatchSorted`, func(b *testing.B) {
+		sorted := rang(benchmarkTreeSize)
+		for i := 0; i < b.N; i++ {
+			tr := New(*btreeDegree)
+			tr.InsertBatch(sorted)
+		}
+	})
+	b.Run(`ReplaceOrInsert`, func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			tr := New(*btreeDegree)
+			for _, item := range insertP {
+				tr.ReplaceOrInsert(item)
+			}
+		}
+	})
+}
+
+func TestDeleteBatch(t *testing.T) {
+	for _, degree := range []int{2, 3, 8} {
+		for _, batchSize := range []int{0, 1, 10, 100, 1000} {
+			tr, want := New(degree), New(degree)
+			for _, v := range perm(500) {
+				tr.ReplaceOrInsert(v)
+				want.ReplaceOrInsert(v)
+			}
+			clone := tr.Clone()
+			var batch []*Item
+			for i := 0; i < batchSize; i++ {
+				batch = append(batch, createItem(rand.Intn(1000)))
+			}
+			before := want.Len()
+			for _, item := range batch {
+				want.Delete(item)
+			}
+			if removed := tr.DeleteBatch(batch); removed != before-want.Len() {
+				t.Fatalf("degree %d, batch %d: removed %d, want %d", degree, batchSize, removed, before-want.Len())
+			}
+			checkTree(t, tr)
+			if got, want := all(tr), all(want); !reflect.DeepEqual(got, want) {
+				t.Fatalf("degree %d, batch %d: mismatch:\n got: %v\nwant: %v", degree, batchSize, got, want)
+			}
+			if got := all(clone); !reflect.DeepEqual(got, rang(500)) {
+				t.Fatalf("degree %d, batch %d: clone was modified", degree, batchSize)
+			}
+		}
+	}
+}
+
+func TestDeleteBatchMulti(t *testing.T) {
+	tr, want := NewMulti(3), NewMulti(3)
+	for i := 0; i < 300; i++ {
+		item := &Item{Key: KeyType(rand.Intn(50)), Payload: i}
+		tr.ReplaceOrInsert(item)
+		want.ReplaceOrInsert(item)
+	}
+	var batch []*Item
+	for i := 0; i < 200; i++ {
+		item := createItem(rand.Intn(60))
+		batch = append(batch, item)
+		want.Delete(item)
+	}
+	tr.DeleteBatch(batch)
+	checkTree(t, tr)
+	if got, want := all(tr), all(want); !reflect.DeepEqual(got, want) {
+		t.Fatalf("mismatch:\n got: %v\nwant: %v", got, want)
+	}
+}
+
+func BenchmarkDeleteBatch(b *testing.B) {
+	insertP := perm(benchmarkTreeSize)
+	removeP := perm(benchmarkTreeSize / 2)
+	b.Run(`DeleteBatch`, func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			tr := New(*btreeDegree)
+			tr.InsertBatch(insertP)
+			b.StartTimer()
+			tr.DeleteBatch(removeP)
+		}
+	})
+	b.Run(`Delete`, func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			tr := New(*btreeDegree)
+			tr.InsertBatch(insertP)
+			b.StartTimer()
+			for _, item := range removeP {
+				tr.Delete(item)
+			}
+		}
+	})
+}
+
+func TestShrinkToFit(t *testing.T) {
+	tr := New(3)
+	if got := tr.ShrinkToFit(); got != 0 {
+		t.Fatalf("empty tree reclaimed %d bytes", got)
+	}
+	for _, v := range perm(1000) {
+		tr.ReplaceOrInsert(v)
+	}
+	clone := tr.Clone()
+	for _, v := range perm(1000)[:900] {
+		tr.Delete(v)
+	}
+	want := all(tr)
+	before := tr.Stats()
+	reclaimed := tr.ShrinkToFit()
+	after := tr.Stats()
+	checkTree(t, tr)
+	if reclaimed <= 0 || reclaimed != before.Bytes-after.Bytes || after.Nodes >= before.Nodes {
+		t.Fatalf("reclaimed %d bytes, stats before %+v, after %+v", reclaimed, before, after)
+	}
+	if got := all(tr); !reflect.DeepEqual(got, want) {
+		t.Fatalf("mismatch:\n got: %v\nwant: %v", got, want)
+	}
+	if got := all(clone); !reflect.DeepEqual(got, rang(1000)) {
+		t.Fatal("clone was modified")
+	}
+}
+
+func TestReserve(t *testing.T) {
+	for _, degree := range []int{2, 3, 8, 32} {
+		tr := New(degree)
+		tr.Reserve(1000)
+		f := tr.cow.freelist
+		reserved := f.Stats().Size
+		for _, item := range rang(1000) {
+			tr.ReplaceOrInsert(item)
+		}
+		checkTree(t, tr)
+		if s := f.Stats(); s.Misses != 0 {
+			t.Errorf("degree %d: %d of %d nodes allocated after reserving %d", degree, s.Misses, tr.Stats().Nodes, reserved)
+		}
+		// Reserving what the free list already holds adds nothing.
+		tr.Clear(true)
+		size := f.Stats().Size
+		tr.Reserve(10)
+		if got := f.Stats().Size; got != size {
+			t.Errorf("degree %d: free list grew from %d to %d", degree, size, got)
+		}
+	}
+}
+
+func BenchmarkReserve(b *testing.B) {
+	insertP := perm(benchmarkTreeSize)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tr := New(*btreeDegree)
+		tr.Reserve(benchmarkTreeSize)
+		for _, item := range insertP {
+			tr.ReplaceOrInsert(item)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// cacheOrder returns the cached keys, most recently used first.
+func cacheOrder(c *CachedTree) (out []KeyType) {
+	for e := c.head.next; e != &c.head; e = e.next {
+		out = append(out, e.key)
+	}
+	return
+}
+
+func TestCachedTree(t *testing.T) {
+	const treeSize = 100
+	tr := New(3)
+	for _, v := range perm(treeSize) {
+		tr.ReplaceOrInsert(v)
+	}
+	c := NewCachedTree(tr, 8)
+	other := New(3)
+	for i := 0; i < 10000; i++ {
+		k := rand.Intn(treeSize+10) - 5
+		key := createItem(k)
+		// Writes go through every kind of path, none of them told about
+		// the cache.
+		switch rand.Intn(12) {
+		case 0:
+			c.ReplaceOrInsert(&Item{Key: key.Key, Payload: i})
+		case 1:
+			tr.Delete(key)
+		case 2:
+			c.DeleteMin()
+		case 3:
+			tr.DeleteRange(key, createItem(k+3))
+		case 4:
+			if tr.Has(key) {
+				tr.Update(key, func(item *Item) { item.Payload = i })
+			}
+		case 5:
+			if rand.Intn(50) == 0 {
+				tr.Clear(rand.Intn(2) == 0)
+			}
+		case 6:
+			tr.InsertBatch([]*Item{createItem(k + 1), createItem(k + 2)})
+		case 7:
+			if tr.Len() == 0 {
+				other.ReplaceOrInsert(key)
+				tr.Merge(other)
+				other.Clear(false)
+			}
+		}
+		if got, want := c.Get(key), tr.Get(key); got != want {
+			t.Fatalf("step %d: Get(%v) = %v, want %v", i, key.Key, got, want)
+		}
+		if len(c.entries) > 8 || len(cacheOrder(c)) != len(c.entries) {
+			t.Fatalf("step %d: %d entries, %d linked", i, len(c.entries), len(cacheOrder(c)))
+		}
+	}
+	checkTree(t, tr)
+}
+
+func TestCachedTreeLRU(t *testing.T) {
+	tr := New(3)
+	for _, v := range perm(10) {
+		tr.ReplaceOrInsert(v)
+	}
+	c := NewCachedTree(tr, 3)
+	for _, k := range []int{1, 2, 3, 1, 4, 20} {
+		c.Get(createItem(k))
+	}
+	if got, want := cacheOrder(c), []KeyType{20, 4, 1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("cached %v, want %v", got, want)
+	}
+	// Reads leave the cache alone.
+	tr.Ascend(func(*Item) bool { return true })
+	c.Get(createItem(4))
+	if got, want := cacheOrder(c), []KeyType{4, 20, 1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("cached %v, want %v", got, want)
+	}
+	// A write made directly to the tree drops the cached miss.
+	tr.ReplaceOrInsert(createItem(20))
+	if !c.Has(createItem(20)) || len(c.entries) != 1 {
+		t.Fatalf("after write: %d entries", len(c.entries))
+	}
+	// So does replacing the tree wholesale.
+	data, err := New(2).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if c.Has(createItem(20)) {
+		t.Fatal("cached hit survived UnmarshalBinary")
+	}
+}
+
+func BenchmarkCachedGet(b *testing.B) {
+	tr := New(*btreeDegree)
+	for _, v := range perm(benchmarkTreeSize) {
+		tr.ReplaceOrInsert(v)
+	}
+	// A skewed workload: most lookups go to a handful of hot keys.
+	keys := make([]*Item, 1024)
+	for i := range keys {
+		keys[i] = createItem(rand.Intn(benchmarkTreeSize))
+		if i%8 != 0 {
+			keys[i] = createItem(rand.Intn(16))
+		}
+	}
+	b.Run("tree", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			tr.Get(keys[i%len(keys)])
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		c := NewCachedTree(tr, 64)
+		for i := 0; i < b.N; i++ {
+			c.Get(keys[i%len(keys)])
+		}
+	})
+}
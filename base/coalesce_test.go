@@ -0,0 +1,144 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCoalescer(t *testing.T) {
+	tr := New(*btreeDegree)
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(v)
+	}
+	c := NewCoalescer(tr, 0)
+	for i := 0; i < 1000; i++ {
+		c.ReplaceOrInsert(&Item{Key: KeyType(i % 10), Payload: i})
+	}
+	for i := 90; i < 110; i++ {
+		c.Delete(createItem(i))
+	}
+	c.ReplaceOrInsert(createItem(95))
+	if c.Pending() != 30 {
+		t.Fatalf("%d pending writes, want 30", c.Pending())
+	}
+	if tr.Len() != 100 || tr.Get(createItem(0)).Payload != nil {
+		t.Fatal("writes reached the tree before flushing")
+	}
+	if got := c.Get(createItem(3)); got == nil || got.Payload != 993 {
+		t.Fatalf("buffered item: got %v", got)
+	}
+	if got := c.Get(createItem(91)); got != nil {
+		t.Fatalf("buffered delete: got %v", got)
+	}
+	if got := c.Get(createItem(50)); got != tr.Get(createItem(50)) {
+		t.Fatalf("unbuffered item: got %v", got)
+	}
+	want := append(rang(90), createItem(95))
+	if got := all(c.Tree()); !reflect.DeepEqual(keys(got), keys(want)) {
+		t.Fatalf("mismatch:\n got: %v\nwant: %v", got, want)
+	}
+	checkTree(t, tr)
+	if c.Pending() != 0 || tr.Get(createItem(3)).Payload != 993 {
+		t.Fatal("flush did not apply the last write")
+	}
+
+	c = NewCoalescer(tr, 5)
+	for i := 0; i < 4; i++ {
+		c.Delete(createItem(i))
+	}
+	if tr.Len() != 91 {
+		t.Fatal("flushed before reaching the limit")
+	}
+	c.Delete(createItem(4))
+	if tr.Len() != 86 || c.Pending() != 0 {
+		t.Fatal("did not flush on reaching the limit")
+	}
+}
+
+func TestCoalescerUpsert(t *testing.T) {
+	tr := New(*btreeDegree)
+	tr.ReplaceOrInsert(&Item{Key: 1, Payload: 100})
+	tr.ReplaceOrInsert(&Item{Key: 3, Payload: 100})
+	merges := 0
+	add := func(existing, new *Item) *Item {
+		merges++
+		return &Item{Key: new.Key, Payload: existing.Payload.(int) + new.Payload.(int)}
+	}
+	c := NewCoalescer(tr, 0)
+	for i := 0; i < 100; i++ {
+		for k := 1; k <= 4; k++ {
+			c.Upsert(&Item{Key: KeyType(k), Payload: 1}, add)
+		}
+		if i == 50 {
+			c.Delete(createItem(3))
+		}
+	}
+	if c.Pending() != 4 || tr.Len() != 2 || tr.Get(createItem(1)).Payload != 100 {
+		t.Fatalf("%d pending, tree holds %d items", c.Pending(), tr.Len())
+	}
+	// Key 4 depends on nothing in the tree, but key 1 has to be flushed to
+	// be read.
+	if got := c.Get(createItem(1)); got == nil || got.Payload != 200 || c.Pending() != 3 {
+		t.Fatalf("upserted item: got %v, %d pending", got, c.Pending())
+	}
+	c.Flush()
+	checkTree(t, tr)
+	for k, want := range map[int]int{1: 200, 2: 100, 3: 49, 4: 100} {
+		if got := tr.Get(createItem(k)); got == nil || got.Payload != want {
+			t.Errorf("key %d: got %v, want %d", k, got, want)
+		}
+	}
+	// Increments were merged in the buffer, except for the first to each
+	// key and the first after the delete, and only key 1 was merged into
+	// the tree, as key 3 was deleted first.
+	if want := 3*99 + 50 + 48 + 1; merges != want {
+		t.Errorf("%d merges, want %d", merges, want)
+	}
+
+	c.Upsert(&Item{Key: 1, Payload: 5}, nil)
+	if got := c.Get(createItem(1)); got.Payload != 5 || c.Pending() != 1 {
+		t.Fatalf("upsert with nil merge: got %v", got)
+	}
+}
+
+func TestCoalescerMaxDelay(t *testing.T) {
+	tr := New(*btreeDegree)
+	c := NewCoalescer(tr, 0)
+	c.SetMaxDelay(20 * time.Millisecond)
+	c.ReplaceOrInsert(createItem(1))
+	c.ReplaceOrInsert(createItem(2))
+	if tr.Len() != 0 {
+		t.Fatal("flushed before the delay")
+	}
+	time.Sleep(30 * time.Millisecond)
+	c.ReplaceOrInsert(createItem(3))
+	if tr.Len() != 3 || c.Pending() != 0 {
+		t.Fatalf("did not flush after the delay: %d items, %d pending", tr.Len(), c.Pending())
+	}
+	c.ReplaceOrInsert(createItem(4))
+	if c.Pending() != 1 {
+		t.Fatal("delay not restarted by the flush")
+	}
+}
+
+func keys(items []*Item) (out []KeyType) {
+	for _, i := range items {
+		out = append(out, i.Key)
+	}
+	return
+}
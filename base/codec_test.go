@@ -0,0 +1,195 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// intPayloadCodec encodes items whose payloads are ints.
+type intPayloadCodec struct{}
+
+func (intPayloadCodec) AppendItem(dst []byte, item *Item) ([]byte, error) {
+	dst = AppendKey(dst, item.Key)
+	return appendUvarint(dst, uint64(item.Payload.(int))), nil
+}
+
+func (intPayloadCodec) DecodeItem(data []byte) (*Item, error) {
+	key, n, err := DecodeKey(data)
+	if err != nil {
+		return nil, err
+	}
+	v, m := binary.Uvarint(data[n:])
+	if m <= 0 {
+		return nil, ErrBadEncoding
+	}
+	return &Item{Key: key, Payload: int(v)}, nil
+}
+
+func TestMarshalBinary(t *testing.T) {
+	multi := NewMulti(2)
+	for _, v := range perm(100) {
+		multi.ReplaceOrInsert(v)
+		multi.ReplaceOrInsert(createItem(int(v.Key)))
+	}
+	for _, tr := range []*BTree{New(2), New(32), NewReverse(3), multi} {
+		if tr != multi {
+			for _, v := range perm(1000) {
+				tr.ReplaceOrInsert(v)
+			}
+		}
+		data, err := tr.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := New(7)
+		if err := got.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		checkTree(t, got)
+		if got.degree != tr.degree || got.reverse != tr.reverse || got.multi != tr.multi {
+			t.Fatalf("got degree %d, reverse %v, multi %v", got.degree, got.reverse, got.multi)
+		}
+		if !reflect.DeepEqual(all(got), all(tr)) {
+			t.Fatalf("mismatch:\n got: %v\nwant: %v", all(got), all(tr))
+		}
+		// The decoded tree takes writes like any other.
+		got.ReplaceOrInsert(createItem(5000))
+		checkTree(t, got)
+
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(tr); err != nil {
+			t.Fatal(err)
+		}
+		var viaGob *BTree
+		if err := gob.NewDecoder(&buf).Decode(&viaGob); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(all(viaGob), all(tr)) {
+			t.Fatalf("gob mismatch:\n got: %v\nwant: %v", all(viaGob), all(tr))
+		}
+	}
+}
+
+func TestMarshalBinaryEmpty(t *testing.T) {
+	data, err := New(4).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := New(2)
+	tr.ReplaceOrInsert(createItem(1))
+	if err := tr.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if tr.Len() != 0 || tr.Min() != nil || tr.degree != 4 {
+		t.Fatalf("got len %d, min %v, degree %d", tr.Len(), tr.Min(), tr.degree)
+	}
+	tr.ReplaceOrInsert(createItem(1))
+	checkTree(t, tr)
+}
+
+func TestItemCodec(t *testing.T) {
+	tr := New(3)
+	for i := 0; i < 100; i++ {
+		tr.ReplaceOrInsert(&Item{Key: KeyType(i), Payload: i * 3})
+	}
+	if _, err := tr.MarshalBinary(); err == nil {
+		t.Fatal("KeyCodec encoded payloads")
+	}
+	tr.SetItemCodec(intPayloadCodec{})
+	data, err := tr.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := New(3)
+	got.SetItemCodec(intPayloadCodec{})
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	checkTree(t, got)
+	for i := 0; i < 100; i++ {
+		if item := got.Get(createItem(i)); item == nil || item.Payload != i*3 {
+			t.Fatalf("item %d: got %v", i, item)
+		}
+	}
+
+	// Gob decodes with DefaultItemCodec.
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(tr); err != nil {
+		t.Fatal(err)
+	}
+	defer func(c ItemCodec) { DefaultItemCodec = c }(DefaultItemCodec)
+	DefaultItemCodec = intPayloadCodec{}
+	var viaGob *BTree
+	if err := gob.NewDecoder(&buf).Decode(&viaGob); err != nil {
+		t.Fatal(err)
+	}
+	if item := viaGob.Get(createItem(10)); item == nil || item.Payload != 30 {
+		t.Fatalf("gob: got %v", item)
+	}
+}
+
+type failingCodec struct{ KeyCodec }
+
+var errFailingCodec = errors.New("failing codec")
+
+func (failingCodec) DecodeItem(data []byte) (*Item, error) {
+	return nil, errFailingCodec
+}
+
+func TestUnmarshalBinaryErrors(t *testing.T) {
+	tr := New(3)
+	for _, v := range perm(10) {
+		tr.ReplaceOrInsert(v)
+	}
+	data, err := tr.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Swapping the first two items, which are encoded at the same size,
+	// puts them out of order.
+	header := 4
+	size := int(data[header]) + 1
+	swapped := append([]byte(nil), data...)
+	copy(swapped[header:], data[header+size:header+2*size])
+	copy(swapped[header+size:], data[header:header+size])
+	for name, bad := range map[string][]byte{
+		"empty":     nil,
+		"version":   append([]byte{0}, data[1:]...),
+		"degree":    append([]byte{data[0], data[1], 1}, data[3:]...),
+		"truncated": data[:len(data)-1],
+		"trailing":  append(append([]byte(nil), data...), 0),
+		"order":     swapped,
+	} {
+		got := New(2)
+		got.ReplaceOrInsert(createItem(42))
+		if err := got.UnmarshalBinary(bad); err != ErrBadEncoding {
+			t.Errorf("%s: got %v, want ErrBadEncoding", name, err)
+		}
+		if got.Len() != 1 {
+			t.Errorf("%s: failed decoding modified the tree", name)
+		}
+	}
+	got := New(2)
+	got.SetItemCodec(failingCodec{})
+	if err := got.UnmarshalBinary(data); err != errFailingCodec {
+		t.Errorf("got %v, want the codec's error", err)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestAscendFromHint(t *testing.T) {
+	const treeSize = 1000
+	for _, degree := range []int{2, 3, 8} {
+		tr, other := New(degree), NewMulti(degree)
+		for _, v := range perm(treeSize) {
+			tr.ReplaceOrInsert(v)
+			other.ReplaceOrInsert(createItem(int(v.Key) / 4))
+		}
+		var hint Cursor
+		for op := 0; op < 2000; op++ {
+			target := tr
+			if op%100 == 99 {
+				target = other // a hint from another tree
+			}
+			if op%10 == 0 {
+				tr.Delete(createItem(rand.Intn(treeSize)))
+				tr.ReplaceOrInsert(createItem(rand.Intn(treeSize)))
+			}
+			pivot := createItem(op/2 + rand.Intn(10) - 5)
+			if op%50 == 0 {
+				pivot = createItem(rand.Intn(treeSize+20) - 10)
+			}
+			n := rand.Intn(20)
+			var got, want []*Item
+			target.AscendFromHint(&hint, pivot, func(i *Item) bool {
+				got = append(got, i)
+				return len(got) < n
+			})
+			target.AscendGreaterOrEqual(pivot, func(i *Item) bool {
+				want = append(want, i)
+				return len(want) < n
+			})
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("degree %d, op %d, pivot %v:\n got: %v\nwant: %v", degree, op, pivot.Key, got, want)
+			}
+		}
+	}
+	var hint Cursor
+	New(2).AscendFromHint(&hint, createItem(1), func(*Item) bool {
+		t.Fatal("empty tree visited an item")
+		return false
+	})
+	tr := NewReverse(3)
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(v)
+	}
+	if got, want := keysOf(func(i ItemIterator) { tr.AscendFromHint(&hint, createItem(10), i) }), seq(10, 0); !reflect.DeepEqual(got, want) {
+		t.Fatalf("reverse: got %v, want %v", got, want)
+	}
+}
+
+func BenchmarkAscendFromHint(b *testing.B) {
+	tr := New(*btreeDegree)
+	for _, v := range perm(benchmarkTreeSize) {
+		tr.ReplaceOrInsert(v)
+	}
+	first := func(*Item) bool { return false }
+	b.ResetTimer()
+	b.Run(`AscendGreaterOrEqual`, func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			tr.AscendGreaterOrEqual(createItem(i%benchmarkTreeSize), first)
+		}
+	})
+	b.Run(`AscendFromHint`, func(b *testing.B) {
+		var hint Cursor
+		for i := 0; i < b.N; i++ {
+			tr.AscendFromHint(&hint, createItem(i%benchmarkTreeSize), first)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugHandler(t *testing.T) {
+	tr := New(3)
+	for _, v := range perm(100) {
+		v.Payload = "secret"
+		tr.ReplaceOrInsert(v)
+	}
+	h := DebugHandler(tr.Clone, func(i *Item) interface{} { return "redacted" })
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/?"+query, nil))
+		return w
+	}
+
+	w := get("format=json&from=10&to=50&limit=30")
+	var page DebugPage
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Len != 100 || len(page.Items) != 30 || page.Items[0].Key != "10" || page.Next == nil || *page.Next != "40" {
+		t.Fatalf("first page: %+v", page)
+	}
+	if page.Items[0].Value != "redacted" {
+		t.Fatalf("value not redacted: %v", page.Items[0])
+	}
+	page = DebugPage{}
+	json.NewDecoder(get("format=json&from=40&to=50&limit=30").Body).Decode(&page)
+	if len(page.Items) != 10 || page.Next != nil {
+		t.Fatalf("last page: %+v", page)
+	}
+
+	w = get("limit=5")
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, "<td>4</td>") || strings.Contains(body, "<td>5</td>") || !strings.Contains(body, "from=5") {
+		t.Fatalf("html page:\n%s", body)
+	}
+	if strings.Contains(body, "secret") {
+		t.Fatal("payload leaked")
+	}
+	for _, query := range []string{"from=x", "to=x", "limit=0", "limit=x"} {
+		if w := get(query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d", query, w.Code)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+type hashPayload uint64
+
+func (p hashPayload) Hash64() uint64 { return uint64(p) }
+
+// bruteDigest digests the range [lo, hi) item by item.
+func bruteDigest(tr *BTree, lo, hi int) (out uint64) {
+	tr.AscendRange(createItem(lo), createItem(hi), func(i *Item) bool {
+		out += itemDigest(i)
+		return true
+	})
+	return
+}
+
+func TestRangeDigest(t *testing.T) {
+	const treeSize = 500
+	a, b := New(2), New(5)
+	for _, v := range perm(treeSize) {
+		a.ReplaceOrInsert(v)
+	}
+	b.InsertBatch(rang(treeSize))
+	if a.RangeDigest(nil, nil) != b.RangeDigest(nil, nil) {
+		t.Fatal("trees with the same items have different digests")
+	}
+	clone := a.Clone()
+	for iter := 0; iter < 1000; iter++ {
+		switch k := rand.Intn(treeSize); rand.Intn(3) {
+		case 0:
+			a.ReplaceOrInsert(createItem(k))
+		case 1:
+			a.Delete(createItem(k))
+		case 2:
+			a.ReplaceOrInsert(&Item{Key: KeyType(k), Payload: hashPayload(iter)})
+		}
+		lo, hi := rand.Intn(treeSize+20)-10, rand.Intn(treeSize+20)-10
+		if got, want := a.RangeDigest(createItem(lo), createItem(hi)), bruteDigest(a, lo, hi); got != want {
+			t.Fatalf("iteration %d, [%d, %d): got %x, want %x", iter, lo, hi, got, want)
+		}
+	}
+	if got, want := clone.RangeDigest(nil, nil), b.RangeDigest(nil, nil); got != want {
+		t.Fatal("writes changed the digest of a clone")
+	}
+	// Payloads that implement Hasher take part in the digest.
+	b.ReplaceOrInsert(&Item{Key: 7, Payload: hashPayload(1)})
+	if b.RangeDigest(createItem(0), createItem(10)) == clone.RangeDigest(createItem(0), createItem(10)) {
+		t.Fatal("changed payload did not change the digest")
+	}
+	if b.RangeDigest(createItem(10), createItem(treeSize)) != clone.RangeDigest(createItem(10), createItem(treeSize)) {
+		t.Fatal("changed payload changed the digest of another range")
+	}
+	if a.RangeDigest(createItem(10), createItem(10)) != 0 {
+		t.Fatal("empty range has a digest")
+	}
+}
+
+func BenchmarkRangeDigest(b *testing.B) {
+	tr := New(*btreeDegree)
+	for _, item := range perm(benchmarkTreeSize) {
+		tr.ReplaceOrInsert(item)
+	}
+	tr.RangeDigest(nil, nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lo := i % (benchmarkTreeSize / 2)
+		tr.RangeDigest(createItem(lo), createItem(lo+benchmarkTreeSize/2))
+	}
+}
+
+func TestItemDigestKeys(t *testing.T) {
+	if itemDigest(createItem(0)) != itemDigest(&Item{Key: KeyType(math.Copysign(0, -1))}) {
+		t.Error("0 and -0 digest differently")
+	}
+	if itemDigest(createItem(1)) == itemDigest(createItem(2)) {
+		t.Error("1 and 2 digest alike")
+	}
+	item := createItem(12345)
+	if n := testing.AllocsPerRun(100, func() { itemDigest(item) }); n != 0 {
+		t.Errorf("%v allocations per digest", n)
+	}
+}
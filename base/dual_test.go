@@ -0,0 +1,116 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"reflect"
+	"testing"
+)
+
+// score returns the Payload of an item as its value in a DualTree.
+func score(i *Item) KeyType {
+	return i.Payload.(KeyType)
+}
+
+func TestDualTree(t *testing.T) {
+	d := NewDual(*btreeDegree, score)
+	for _, v := range perm(100) {
+		d.ReplaceOrInsert(&Item{Key: v.Key, Payload: KeyType(int(v.Key) % 10)})
+	}
+	if d.Len() != 100 || d.byValue.Len() != 100 {
+		t.Fatalf("lens %d, %d", d.Len(), d.byValue.Len())
+	}
+	var got []int
+	d.AscendValues(&Item{Key: 3}, &Item{Key: 4}, func(i *Item) bool {
+		got = append(got, int(i.Key))
+		return true
+	})
+	if len(got) != 10 || d.Get(createItem(got[0])).Payload != KeyType(3) {
+		t.Fatalf("value 3: got %v", got)
+	}
+	// Moving item 13 to value 9 puts it after the items already there.
+	old := d.ReplaceOrInsert(&Item{Key: 13, Payload: KeyType(9)})
+	if old == nil || old.Payload != KeyType(3) {
+		t.Fatalf("replaced %v", old)
+	}
+	before := got
+	got = nil
+	d.AscendValues(&Item{Key: 3}, &Item{Key: 4}, func(i *Item) bool {
+		got = append(got, int(i.Key))
+		return true
+	})
+	var want []int
+	for _, k := range before {
+		if k != 13 {
+			want = append(want, k)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("value 3 after move: got %v, want %v", got, want)
+	}
+	got = nil
+	d.DescendValues(nil, &Item{Key: 8}, func(i *Item) bool {
+		got = append(got, int(i.Key))
+		return len(got) < 11
+	})
+	if len(got) != 11 || got[0] != 13 {
+		t.Fatalf("descending values: got %v", got)
+	}
+	for i := 0; i < 100; i++ {
+		d.Delete(createItem(i))
+	}
+	if d.Len() != 0 || d.byValue.Len() != 0 {
+		t.Fatalf("lens %d, %d after deleting everything", d.Len(), d.byValue.Len())
+	}
+}
+
+func TestLeaderboard(t *testing.T) {
+	d := NewDual(*btreeDegree, score)
+	// Players 0-9 score 10 times their id modulo 5, so players i and i+5 tie
+	// and the one inserted first ranks higher.
+	for _, i := range []int{0, 1, 2, 3, 4, 9, 8, 7, 6, 5} {
+		d.ReplaceOrInsert(&Item{Key: KeyType(i), Payload: KeyType(10 * (i % 5))})
+	}
+	want := []int{4, 9, 3, 8, 2, 7, 1, 6, 0, 5}
+	for rank, id := range want {
+		if got := d.RankOf(createItem(id)); got != rank {
+			t.Errorf("rank of %d: got %d, want %d", id, got, rank)
+		}
+	}
+	if got := d.RankOf(createItem(10)); got != -1 {
+		t.Errorf("rank of missing player: got %d", got)
+	}
+	ids := func(items []*Item) (out []int) {
+		for _, i := range items {
+			out = append(out, int(i.Key))
+		}
+		return
+	}
+	if got := ids(d.TopN(3)); !reflect.DeepEqual(got, want[:3]) {
+		t.Errorf("top 3: got %v, want %v", got, want[:3])
+	}
+	if got := ids(d.TopN(20)); !reflect.DeepEqual(got, want) {
+		t.Errorf("top 20: got %v, want %v", got, want)
+	}
+	if got := ids(d.Around(createItem(2), 2)); !reflect.DeepEqual(got, want[2:7]) {
+		t.Errorf("around 2: got %v, want %v", got, want[2:7])
+	}
+	if got := ids(d.Around(createItem(9), 3)); !reflect.DeepEqual(got, want[:5]) {
+		t.Errorf("around 9: got %v, want %v", got, want[:5])
+	}
+	if got := d.Around(createItem(10), 3); got != nil {
+		t.Errorf("around missing player: got %v", got)
+	}
+}
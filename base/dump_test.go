@@ -0,0 +1,103 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func ExampleBTree_Dump() {
+	tr := New(2)
+	tr.InsertBatch(rang(10))
+	tr.Dump(os.Stdout)
+	// Output:
+	// 0: [3 7]
+	// 1: [0 1 2] [4 5 6] [8 9]
+}
+
+func ExampleBTree_DumpDot() {
+	tr := New(2)
+	tr.InsertBatch(rang(5))
+	tr.DumpDot(os.Stdout, func(i *Item) string {
+		return fmt.Sprintf("<%v>", i.Key)
+	})
+	// Output:
+	// digraph btree {
+	// 	node [shape=record];
+	// 	n0 [label="<c0>|\<2\>|<c1>"];
+	// 	n1 [label="\<0\>|\<1\>"];
+	// 	n0:c0 -> n1;
+	// 	n2 [label="\<3\>|\<4\>"];
+	// 	n0:c1 -> n2;
+	// }
+}
+
+func TestLoadDump(t *testing.T) {
+	f, err := os.Open("testdata/borrow.dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	want, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := LoadDump(2, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	tr.Dump(&buf)
+	if buf.String() != string(want) {
+		t.Fatalf("round trip mismatch:\n got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+	if got := all(tr); !reflect.DeepEqual(got, rang(10)) {
+		t.Fatalf("items mismatch: %v", got)
+	}
+	// The rightmost leaf holds the minimum, so deleting from it must borrow
+	// across two levels.
+	tr.Delete(createItem(9))
+	checkTree(t, tr)
+	if got := all(tr); !reflect.DeepEqual(got, rang(9)) {
+		t.Fatalf("items after delete mismatch: %v", got)
+	}
+
+	if tr, err := LoadDump(2, strings.NewReader("")); err != nil || tr.Len() != 0 {
+		t.Fatalf("empty dump: %v, %v", tr, err)
+	}
+	for _, bad := range []string{
+		"1: [1]\n",
+		"0: [1] [2]\n",
+		"0: [1\n",
+		"0: [x]\n",
+		"0: [2]\n1: [1]\n",
+		"0: [2]\n1: [1] [3] [4]\n",
+		"0: [2]\n1: [3] [1]\n",
+		"0: [1 2 3 4]\n",
+		"0: [5]\n1: [1] []\n",
+		"0: []\n1: [1]\n",
+		"0: [3]\n1: [1] [4]\n2: [0] [2] [] [5]\n",
+	} {
+		if _, err := LoadDump(2, strings.NewReader(bad)); err == nil {
+			t.Errorf("%q: no error", bad)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import "testing"
+
+func TestGuardIterator(t *testing.T) {
+	tr := New(*btreeDegree)
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(v)
+	}
+	cleanups := 0
+	func() {
+		defer func() {
+			p, ok := recover().(*IteratorPanic)
+			if !ok {
+				t.Fatalf("got panic %v, want an *IteratorPanic", p)
+			}
+			if p.Key != 42 || p.Value != "boom" {
+				t.Fatalf("got %+v", p)
+			}
+		}()
+		tr.Ascend(GuardIterator(func(i *Item) bool {
+			if i.Key == 42 {
+				panic("boom")
+			}
+			return true
+		}, func() { cleanups++ }))
+	}()
+	if cleanups != 1 {
+		t.Fatalf("cleanup ran %d times, want 1", cleanups)
+	}
+	checkTree(t, tr)
+	// Without a panic, the guarded iterator behaves like the one it wraps.
+	n := 0
+	tr.AscendRange(createItem(10), createItem(20), GuardIterator(func(i *Item) bool {
+		n++
+		return true
+	}, nil))
+	if n != 10 || cleanups != 1 {
+		t.Fatalf("visited %d items, %d cleanups", n, cleanups)
+	}
+}
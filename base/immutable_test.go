@@ -0,0 +1,61 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFreeze(t *testing.T) {
+	tr := New(*btreeDegree)
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(v)
+	}
+	snap := tr.Freeze()
+	for _, v := range perm(100)[:50] {
+		tr.Delete(v)
+	}
+	tr.ReplaceOrInsert(createItem(100))
+	if snap.Len() != 100 || !snap.Has(createItem(0)) || snap.Has(createItem(100)) {
+		t.Fatal("writes to the tree leaked into the snapshot")
+	}
+	var got []*Item
+	snap.Ascend(func(i *Item) bool {
+		got = append(got, i)
+		return true
+	})
+	if want := rang(100); !reflect.DeepEqual(got, want) {
+		t.Fatalf("mismatch:\n got: %v\nwant: %v", got, want)
+	}
+	got = got[:0]
+	snap.DescendRange(createItem(60), createItem(50), func(i *Item) bool {
+		got = append(got, i)
+		return true
+	})
+	if want := rangrev(61)[:10]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("mismatch:\n got: %v\nwant: %v", got, want)
+	}
+	if snap.Min().Key != 0 || snap.Max().Key != 99 || snap.CountRange(nil, createItem(10)) != 10 {
+		t.Fatal("snapshot bounds mismatch")
+	}
+
+	thawed := snap.Thaw()
+	thawed.DeleteRange(nil, createItem(90))
+	checkTree(t, thawed)
+	if thawed.Len() != 10 || snap.Len() != 100 || !snap.Has(createItem(0)) {
+		t.Fatal("writes to the thawed tree leaked into the snapshot")
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import "testing"
+
+func TestItemPool(t *testing.T) {
+	p := NewItemPool(2)
+	a := p.Acquire(1)
+	a.Payload = "a"
+	p.Release(a)
+	if b := p.Acquire(2); b != a || b.Key != 2 || b.Payload != nil {
+		t.Fatalf("got %+v, want recycled %p with key 2", b, a)
+	}
+	for i := 0; i < 3; i++ {
+		p.Release(&Item{})
+	}
+	if len(p.items) != 2 {
+		t.Fatalf("pool holds %d items, want 2", len(p.items))
+	}
+
+	tr := New(*btreeDegree)
+	tr.SetItemPool(p)
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(p.Acquire(v.Key))
+	}
+	if len(p.items) != 0 {
+		t.Fatalf("pool holds %d items after acquiring", len(p.items))
+	}
+	// Items handed back to the caller stay out of the pool, intact.
+	tr.Get(createItem(50)).Payload = "payload"
+	if old := tr.Delete(createItem(50)); old == nil || old.Key != 50 || old.Payload != "payload" {
+		t.Fatalf("Delete returned %+v", old)
+	}
+	if tr.DeleteMin() == nil || len(p.items) != 0 {
+		t.Fatalf("pool holds %d items after deletes returning them", len(p.items))
+	}
+	checkTree(t, tr)
+}
+
+func TestItemPoolRemovals(t *testing.T) {
+	for _, tr := range []*BTree{New(3), NewMulti(3)} {
+		p := NewItemPool(1000)
+		tr.SetItemPool(p)
+		for i := 0; i < 200; i++ {
+			tr.ReplaceOrInsert(p.Acquire(KeyType(i/2 + 1)))
+		}
+		total, released := tr.Len(), 0
+		check := func(what string, out []*Item, n int) {
+			t.Helper()
+			for _, item := range out {
+				if *item == (Item{}) {
+					t.Fatalf("multi %v, %s: returned item was released", tr.multi, what)
+				}
+			}
+			if released += n; len(p.items) != released {
+				t.Fatalf("multi %v, %s: pool holds %d items, want %d", tr.multi, what, len(p.items), released)
+			}
+			checkTree(t, tr)
+		}
+		out := tr.DeleteMinN(5)
+		check("DeleteMinN", out, 0)
+		out = tr.DeleteMaxN(5)
+		check("DeleteMaxN", out, 0)
+		total -= 10
+		n := tr.DeleteRange(createItem(20), createItem(30))
+		check("DeleteRange", nil, n)
+		var batch []*Item
+		for i := 30; i < 60; i++ {
+			batch = append(batch, createItem(i))
+		}
+		n = tr.DeleteBatch(batch)
+		check("DeleteBatch", nil, n)
+		n = tr.DeleteAll(createItem(60))
+		check("DeleteAll", nil, n)
+		var seen int
+		tr.ClearFunc(func(i *Item) {
+			if *i == (Item{}) {
+				t.Fatal("ClearFunc passed a released item")
+			}
+			seen++
+		})
+		check("ClearFunc", nil, seen)
+		if released != total {
+			t.Fatalf("multi %v: %d released, want %d", tr.multi, released, total)
+		}
+	}
+	// Clear and ClearIncremental drop whole trees.
+	p := NewItemPool(1000)
+	for _, clear := range []func(*BTree){
+		func(tr *BTree) { tr.Clear(false) },
+		func(tr *BTree) {
+			for r := tr.ClearIncremental(3); r.Next(); {
+			}
+		},
+	} {
+		tr := New(3)
+		tr.SetItemPool(p)
+		for i := 0; i < 100; i++ {
+			tr.ReplaceOrInsert(p.Acquire(KeyType(i)))
+		}
+		clear(tr)
+		if len(p.items) != 100 {
+			t.Fatalf("pool holds %d items after clearing, want 100", len(p.items))
+		}
+	}
+}
+
+func BenchmarkItemPool(b *testing.B) {
+	p := NewItemPool(DefaultFreeListSize)
+	tr := New(*btreeDegree)
+	tr.SetItemPool(p)
+	for _, v := range perm(benchmarkTreeSize) {
+		tr.ReplaceOrInsert(p.Acquire(v.Key))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := KeyType(i % benchmarkTreeSize)
+		p.Release(tr.Delete(&Item{Key: key}))
+		tr.ReplaceOrInsert(p.Acquire(key))
+	}
+}
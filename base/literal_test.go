@@ -0,0 +1,73 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMustBuild(t *testing.T) {
+	tr := MustBuild(2, KV{1, "a"}, KV{2, "b"}, KV{3, "c"}, KV{4, "d"})
+	checkTree(t, tr)
+	if v, ok := tr.GetValue(3); !ok || v != "c" || tr.Len() != 4 {
+		t.Fatalf("got %v, %v, len %d", v, ok, tr.Len())
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("out of order pairs did not panic")
+		}
+	}()
+	MustBuild(2, KV{Key: 2}, KV{Key: 1})
+}
+
+func TestMustBuildNodes(t *testing.T) {
+	tr := MustBuildNodes(2, NodeLiteral{
+		Keys: []KeyType{3},
+		Children: []NodeLiteral{
+			{Keys: []KeyType{1, 2}},
+			{Keys: []KeyType{4}},
+		},
+	})
+	var buf bytes.Buffer
+	tr.Dump(&buf)
+	if got, want := buf.String(), "0: [3]\n1: [1 2] [4]\n"; got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if tr.Len() != 4 {
+		t.Fatalf("len %d, want 4", tr.Len())
+	}
+	// Deleting 4 makes the right leaf borrow from its sibling.
+	tr.Delete(createItem(4))
+	checkTree(t, tr)
+	if MustBuildNodes(3, NodeLiteral{}).Len() != 0 {
+		t.Fatal("empty literal gave a non-empty tree")
+	}
+	for _, bad := range []NodeLiteral{
+		{Keys: []KeyType{3}, Children: []NodeLiteral{{Keys: []KeyType{1}}}},
+		{Keys: []KeyType{3}, Children: []NodeLiteral{{Keys: []KeyType{1}}, {}}},
+		{Children: []NodeLiteral{{Keys: []KeyType{1}}}},
+		{Keys: []KeyType{3}, Children: []NodeLiteral{{Keys: []KeyType{1}}, {Keys: []KeyType{4}}, {Keys: []KeyType{5}}}},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("invalid structure %v did not panic", bad)
+				}
+			}()
+			MustBuildNodes(2, bad)
+		}()
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// multiModel mirrors a multi tree as a slice sorted by key, then insertion.
+type multiModel []*Item
+
+func (m *multiModel) insert(item *Item) {
+	i := sort.Search(len(*m), func(i int) bool { return item.Less((*m)[i]) })
+	*m = append(*m, nil)
+	copy((*m)[i+1:], (*m)[i:])
+	(*m)[i] = item
+}
+
+func (m *multiModel) deleteOne(key *Item) *Item {
+	i := sort.Search(len(*m), func(i int) bool { return !(*m)[i].Less(key) })
+	if i == len(*m) || key.Less((*m)[i]) {
+		return nil
+	}
+	out := (*m)[i]
+	*m = append((*m)[:i], (*m)[i+1:]...)
+	return out
+}
+
+func (m *multiModel) deleteMatch(key *Item, match func(*Item) bool) *Item {
+	for i, item := range *m {
+		if !item.Less(key) && !key.Less(item) && match(item) {
+			*m = append((*m)[:i], (*m)[i+1:]...)
+			return item
+		}
+	}
+	return nil
+}
+
+func TestMulti(t *testing.T) {
+	const keys = 50
+	for _, degree := range []int{2, 3, 4, 8} {
+		tr := NewMulti(degree)
+		var model multiModel
+		for op := 0; op < 5000; op++ {
+			key := createItem(rand.Intn(keys))
+			switch rand.Intn(3) {
+			case 0, 1:
+				item := &Item{Key: key.Key, Payload: op}
+				if out := tr.ReplaceOrInsert(item); out != nil {
+					t.Fatalf("multi insert replaced %v", out)
+				}
+				model.insert(item)
+			case 2:
+				if got, want := tr.DeleteOne(key), model.deleteOne(key); got != want {
+					t.Fatalf("degree %d: DeleteOne(%v): got %v, want %v", degree, key.Key, got, want)
+				}
+			}
+		}
+		checkTree(t, tr)
+		if got := all(tr); !reflect.DeepEqual(got, []*Item(model)) {
+			t.Fatalf("degree %d: mismatch:\n got: %v\nwant: %v", degree, got, model)
+		}
+		for k := 0; k < keys; k++ {
+			var want []*Item
+			for _, item := range model {
+				if int(item.Key) == k {
+					want = append(want, item)
+				}
+			}
+			if got := tr.GetAll(createItem(k)); !reflect.DeepEqual(got, want) {
+				t.Fatalf("degree %d: GetAll(%d):\n got: %v\nwant: %v", degree, k, got, want)
+			}
+			if got := tr.DeleteAll(createItem(k)); got != len(want) {
+				t.Fatalf("degree %d: DeleteAll(%d) removed %d, want %d", degree, k, got, len(want))
+			}
+			checkTree(t, tr)
+		}
+		if tr.Len() != 0 {
+			t.Fatalf("degree %d: %d items left", degree, tr.Len())
+		}
+	}
+}
+
+func TestMultiRanges(t *testing.T) {
+	tr := NewMulti(2)
+	for i := 0; i < 3; i++ {
+		for _, v := range perm(20) {
+			tr.ReplaceOrInsert(v)
+		}
+	}
+	var got []int
+	tr.AscendRange(createItem(5), createItem(7), func(i *Item) bool {
+		got = append(got, int(i.Key))
+		return true
+	})
+	if want := []int{5, 5, 5, 6, 6, 6}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ascend: got %v, want %v", got, want)
+	}
+	got = got[:0]
+	tr.DescendRange(createItem(7), createItem(5), func(i *Item) bool {
+		got = append(got, int(i.Key))
+		return true
+	})
+	if want := []int{7, 7, 7, 6, 6, 6}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("descend: got %v, want %v", got, want)
+	}
+	if removed := tr.DeleteRange(createItem(5), createItem(7)); removed != 6 {
+		t.Fatalf("DeleteRange removed %d, want 6", removed)
+	}
+	checkTree(t, tr)
+	if tr.Len() != 54 || tr.Has(createItem(6)) {
+		t.Fatalf("wrong items left after DeleteRange")
+	}
+}
+
+func TestMultiMatch(t *testing.T) {
+	// Few keys, so runs of equal items span many nodes.
+	const keys = 5
+	for _, degree := range []int{2, 3, 8} {
+		tr := NewMulti(degree)
+		var model multiModel
+		for op := 0; op < 3000; op++ {
+			key := createItem(rand.Intn(keys))
+			owner := rand.Intn(20)
+			match := func(i *Item) bool { return i.Payload.(int)%20 == owner }
+			switch rand.Intn(3) {
+			case 0, 1:
+				item := &Item{Key: key.Key, Payload: op}
+				tr.ReplaceOrInsert(item)
+				model.insert(item)
+			case 2:
+				if got, want := tr.GetMatch(key, match), tr.DeleteMatch(key, match); got != want {
+					t.Fatalf("degree %d: GetMatch got %v, DeleteMatch %v", degree, got, want)
+				} else if want2 := model.deleteMatch(key, match); got != want2 {
+					t.Fatalf("degree %d: DeleteMatch(%v, %d): got %v, want %v", degree, key.Key, owner, got, want2)
+				}
+			}
+		}
+		checkTree(t, tr)
+		if got := all(tr); !reflect.DeepEqual(got, []*Item(model)) {
+			t.Fatalf("degree %d: mismatch:\n got: %v\nwant: %v", degree, got, model)
+		}
+	}
+	if New(2).DeleteMatch(createItem(1), func(*Item) bool { return true }) != nil {
+		t.Fatal("deleted from empty tree")
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMultiIndex(t *testing.T) {
+	// Index 0 files items by their key modulo 10, index 1 by their payload.
+	m := NewMultiIndex(*btreeDegree,
+		func(i *Item) KeyType { return KeyType(int(i.Key) % 10) },
+		func(i *Item) KeyType { return i.Payload.(KeyType) },
+	)
+	for _, v := range perm(100) {
+		m.ReplaceOrInsert(&Item{Key: v.Key, Payload: KeyType(99) - v.Key})
+	}
+	if m.Len() != 100 {
+		t.Fatalf("len %d, want 100", m.Len())
+	}
+	var got []KeyType
+	for _, item := range m.GetByIndex(0, 3) {
+		got = append(got, item.Key)
+	}
+	if want := []KeyType{3, 13, 23, 33, 43, 53, 63, 73, 83, 93}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("by index 0: got %v, want %v", got, want)
+	}
+	got = got[:0]
+	m.AscendIndex(1, &Item{Key: 95}, nil, func(i *Item) bool {
+		got = append(got, i.Key)
+		return true
+	})
+	if want := []KeyType{4, 3, 2, 1, 0}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("by index 1: got %v, want %v", got, want)
+	}
+
+	// Replacing an item refiles it; deleting one unfiles it.
+	old := m.ReplaceOrInsert(&Item{Key: 3, Payload: KeyType(1000)})
+	if old == nil || old.Payload != KeyType(96) {
+		t.Fatalf("replaced %v", old)
+	}
+	if got := m.GetByIndex(1, 96); got != nil {
+		t.Fatalf("replaced item still filed: %v", got)
+	}
+	if got := m.GetByIndex(1, 1000); len(got) != 1 || got[0].Key != 3 {
+		t.Fatalf("new item not filed: %v", got)
+	}
+	for i := 0; i < 100; i += 10 {
+		m.Delete(createItem(i))
+	}
+	if got := m.GetByIndex(0, 0); got != nil {
+		t.Fatalf("deleted items still filed: %v", got)
+	}
+	if m.secondary[0].Len() != 9 || m.secondary[1].Len() != 90 || m.Len() != 90 {
+		t.Fatalf("lens %d, %d, %d", m.secondary[0].Len(), m.secondary[1].Len(), m.Len())
+	}
+	n := 0
+	m.AscendIndex(0, nil, nil, func(i *Item) bool {
+		n++
+		return n < 15
+	})
+	if n != 15 {
+		t.Fatalf("iteration did not stop: %d", n)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import "testing"
+
+func TestVersioned(t *testing.T) {
+	v := NewVersioned(*btreeDegree, 0)
+	if v.Rev() != 0 || v.Current().Len() != 0 {
+		t.Fatalf("new tree at rev %d with %d items", v.Rev(), v.Current().Len())
+	}
+	for i := 0; i < 100; i++ {
+		rev := v.Update(func(tr *BTree) {
+			tr.ReplaceOrInsert(createItem(i))
+		})
+		if rev != int64(i+1) {
+			t.Fatalf("update %d gave rev %d", i, rev)
+		}
+	}
+	for rev := int64(0); rev <= 100; rev++ {
+		snap, err := v.At(rev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if snap.Len() != int(rev) {
+			t.Fatalf("rev %d has %d items", rev, snap.Len())
+		}
+	}
+	if _, err := v.At(101); err != ErrFutureRev {
+		t.Fatalf("future rev: got %v", err)
+	}
+	old, _ := v.At(50)
+	v.Compact(60)
+	if _, err := v.At(59); err != ErrCompacted {
+		t.Fatalf("compacted rev: got %v", err)
+	}
+	if snap, err := v.At(60); err != nil || snap.Len() != 60 {
+		t.Fatalf("rev 60 after compaction: %v", err)
+	}
+	if old.Len() != 50 || !old.Has(createItem(49)) || old.Has(createItem(50)) {
+		t.Fatal("snapshot changed by compaction")
+	}
+	v.Compact(1000)
+	if v.Rev() != 100 || v.Current().Len() != 100 {
+		t.Fatal("compaction dropped the latest revision")
+	}
+}
+
+func TestVersionedKeep(t *testing.T) {
+	v := NewVersioned(*btreeDegree, 3)
+	for i := 0; i < 10; i++ {
+		v.Update(func(tr *BTree) {
+			tr.ReplaceOrInsert(createItem(i))
+		})
+	}
+	if _, err := v.At(7); err != ErrCompacted {
+		t.Fatalf("rev 7: got %v", err)
+	}
+	for rev := int64(8); rev <= 10; rev++ {
+		if snap, err := v.At(rev); err != nil || snap.Len() != int(rev) {
+			t.Fatalf("rev %d: %v", rev, err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestQuantileWindow(t *testing.T) {
+	// Item i has a random value among 0 to 49, so many values are shared.
+	values := make([]int, 1000)
+	for i := range values {
+		values[i] = rand.Intn(50)
+	}
+	value := func(i *Item) KeyType { return KeyType(values[int(i.Key)]) }
+	w := NewCountWindow(*btreeDegree, 100)
+	w.Append(createItem(0))
+	qw := NewQuantileWindow(w, value)
+	if qw.Quantile(0.5) != w.Get(createItem(0)) {
+		t.Fatal("existing item not included")
+	}
+	for i := 1; i < 1000; i++ {
+		qw.Append(createItem(i))
+		if i%97 != 0 {
+			continue
+		}
+		from := 0
+		if i >= 100 {
+			from = i - 99
+		}
+		want := append([]int(nil), values[from:i+1]...)
+		sort.Ints(want)
+		for _, q := range []float64{0, 0.25, 0.5, 0.99, 1} {
+			rank := int(q*float64(len(want))+0.9999) - 1
+			if rank < 0 {
+				rank = 0
+			}
+			if got := qw.Quantile(q); got == nil || int(value(got)) != want[rank] {
+				t.Fatalf("after %d: quantile %v is %v, want value %d", i, q, got, want[rank])
+			}
+		}
+	}
+	if qw.Len() != 100 || qw.values.byValue.Len() != 100 {
+		t.Fatalf("%d items, %d values", qw.Len(), qw.values.byValue.Len())
+	}
+	checkTree(t, qw.values.byValue)
+}
+
+func TestQuantileKeyWindow(t *testing.T) {
+	w := NewKeyWindow(3, func(newest *Item) *Item {
+		return &Item{Key: newest.Key - 10}
+	})
+	qw := NewQuantileWindow(w, func(i *Item) KeyType { return -i.Key })
+	if qw.Quantile(0.5) != nil {
+		t.Fatal("quantile of empty window")
+	}
+	for _, v := range rang(100) {
+		qw.Append(v)
+	}
+	// The window holds 89 to 99, valued -99 to -89.
+	if got := qw.Quantile(0.5); got.Key != 94 {
+		t.Fatalf("median %v, want 94", got)
+	}
+	if got := qw.Quantile(0); got.Key != 99 {
+		t.Fatalf("minimum %v, want 99", got)
+	}
+	if qw.values.byValue.Len() != 11 {
+		t.Fatalf("%d values, want 11", qw.values.byValue.Len())
+	}
+}
+
+func TestNth(t *testing.T) {
+	tr := New(3)
+	for _, v := range perm(500) {
+		tr.ReplaceOrInsert(v)
+	}
+	for i := 0; i < 500; i++ {
+		if got := tr.root.nth(i); int(got.Key) != i {
+			t.Fatalf("nth(%d) = %v", i, got)
+		}
+	}
+}
+
+func BenchmarkQuantileWindowTies(b *testing.B) {
+	// Latencies bucketed to a few values, so every value has many ties.
+	value := func(i *Item) KeyType { return KeyType(int(i.Key) % 4) }
+	qw := NewQuantileWindow(NewCountWindow(*btreeDegree, 10000), value)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		qw.Append(createItem(i))
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// checkRangeSet fails the test if s does not hold exactly the keys set in
+// want, as the fewest disjoint intervals.
+func checkRangeSet(t *testing.T, s *RangeSet, want []bool) {
+	t.Helper()
+	checkTree(t, s.tree)
+	got := make([]bool, len(want))
+	var last KeyType = -1
+	s.Ascend(func(start, end KeyType) bool {
+		if !(start < end) || !(last < start) {
+			t.Fatalf("interval [%v, %v) after one ending at %v", start, end, last)
+		}
+		for k := int(start); k < int(end); k++ {
+			got[k] = true
+		}
+		last = end
+		return true
+	})
+	for k := range want {
+		if got[k] != want[k] || s.Contains(KeyType(k)) != want[k] {
+			t.Fatalf("key %d: got %v, contains %v, want %v", k, got[k], s.Contains(KeyType(k)), want[k])
+		}
+	}
+}
+
+func TestRangeSet(t *testing.T) {
+	const keys = 200
+	s := NewRangeSet(3)
+	want := make([]bool, keys)
+	for op := 0; op < 2000; op++ {
+		start := rand.Intn(keys)
+		end := start + rand.Intn(20)
+		if end > keys {
+			end = keys
+		}
+		add := rand.Intn(2) == 0
+		if add {
+			s.Add(KeyType(start), KeyType(end))
+		} else {
+			s.Remove(KeyType(start), KeyType(end))
+		}
+		for k := start; k < end; k++ {
+			want[k] = add
+		}
+		checkRangeSet(t, s, want)
+	}
+	c := s.Complement(10, keys-10)
+	cwant := make([]bool, keys)
+	for k := 10; k < keys-10; k++ {
+		cwant[k] = !want[k]
+	}
+	checkRangeSet(t, c, cwant)
+	if c := s.Complement(5, 5); c.Len() != 0 {
+		t.Fatalf("complement of empty range has %d intervals", c.Len())
+	}
+}
+
+func TestRangeSetMerge(t *testing.T) {
+	s := NewRangeSet(2)
+	s.Add(0, 10)
+	s.Add(20, 30)
+	s.Add(10, 20)
+	if s.Len() != 1 || !s.Contains(15) || s.Contains(30) {
+		t.Fatalf("touching intervals not merged: %d intervals", s.Len())
+	}
+	s.Remove(5, 25)
+	if s.Len() != 2 || s.Contains(5) || !s.Contains(4) || !s.Contains(25) {
+		t.Fatalf("interval not split: %d intervals", s.Len())
+	}
+}
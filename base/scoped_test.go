@@ -0,0 +1,71 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDelegate(t *testing.T) {
+	tr := New(*btreeDegree)
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(v)
+	}
+	s := tr.Delegate(createItem(20), createItem(30))
+	if s.Len() != 10 || s.Min().Key != 20 || s.Max().Key != 29 {
+		t.Fatalf("len %d, min %v, max %v", s.Len(), s.Min(), s.Max())
+	}
+	if s.Has(createItem(30)) || s.Get(createItem(19)) != nil || !s.Has(createItem(25)) {
+		t.Fatal("view sees items out of its range")
+	}
+	if got := keysOf(s.Ascend); !reflect.DeepEqual(got, seq(20, 29)) {
+		t.Fatalf("ascend: got %v", got)
+	}
+	if got := keysOf(s.Descend); !reflect.DeepEqual(got, seq(29, 20)) {
+		t.Fatalf("descend: got %v", got)
+	}
+	got := keysOf(func(i ItemIterator) { s.AscendRange(createItem(25), createItem(50), i) })
+	if !reflect.DeepEqual(got, seq(25, 29)) {
+		t.Fatalf("ascend range: got %v", got)
+	}
+	if s.Delete(createItem(50)) != nil || s.DeleteRange(nil, nil) != 10 || tr.Len() != 90 {
+		t.Fatalf("deletes reached out of the view, len %d", tr.Len())
+	}
+	s.ReplaceOrInsert(createItem(21))
+	if s.DeleteMin().Key != 21 || s.DeleteMax() != nil || s.Min() != nil {
+		t.Fatal("empty view not empty")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("insert out of the view did not panic")
+			}
+		}()
+		s.ReplaceOrInsert(createItem(30))
+	}()
+
+	// Scopes follow the order of reversed trees.
+	rev := NewReverse(3)
+	rev.InsertBatch(rang(100))
+	rs := rev.Delegate(createItem(30), nil)
+	if got := keysOf(rs.Descend); !reflect.DeepEqual(got, seq(0, 30)) {
+		t.Fatalf("reversed descend: got %v", got)
+	}
+	if rs.Len() != 31 || rs.Clear() != 31 || rev.Len() != 69 {
+		t.Fatalf("reversed clear: len %d", rev.Len())
+	}
+	checkTree(t, rev)
+}
@@ -0,0 +1,180 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// randomSet returns a tree of the given degree holding each of the keys 0 to
+// n-1 with probability p, along with the set of keys.
+func randomSet(degree, n int, p float64) (*BTree, map[int]bool) {
+	tr, keys := New(degree), map[int]bool{}
+	for _, v := range perm(n) {
+		if rand.Float64() < p {
+			tr.ReplaceOrInsert(v)
+			keys[int(v.Key)] = true
+		}
+	}
+	return tr, keys
+}
+
+func TestWalker(t *testing.T) {
+	for _, degree := range []int{2, 3, 8} {
+		tr, _ := randomSet(degree, 500, 0.5)
+		w := newWalker(tr)
+		var got []*Item
+		for i := w.next(); i != nil; i = w.next() {
+			got = append(got, i)
+		}
+		if want := all(tr); !reflect.DeepEqual(got, want) {
+			t.Fatalf("degree %d: mismatch:\n got: %v\nwant: %v", degree, got, want)
+		}
+	}
+	if newWalker(New(2)).next() != nil {
+		t.Fatal("empty tree returned an item")
+	}
+}
+
+func TestUnion(t *testing.T) {
+	for _, degree := range []int{2, 3, 8} {
+		a, ka := randomSet(degree, 500, 0.3)
+		b, kb := randomSet(degree, 500, 0.6)
+		for _, v := range all(b) {
+			v.Payload = "b"
+		}
+		u := Union(a, b, func(x, y *Item) *Item {
+			return &Item{Key: x.Key, Payload: "both"}
+		})
+		checkTree(t, u)
+		var want []*Item
+		for k := 0; k < 500; k++ {
+			switch {
+			case ka[k] && kb[k]:
+				want = append(want, &Item{Key: KeyType(k), Payload: "both"})
+			case ka[k]:
+				want = append(want, a.Get(createItem(k)))
+			case kb[k]:
+				want = append(want, b.Get(createItem(k)))
+			}
+		}
+		if got := all(u); !reflect.DeepEqual(got, want) {
+			t.Fatalf("degree %d: mismatch:\n got: %v\nwant: %v", degree, got, want)
+		}
+		if u := Union(a, b, nil); u.Len() != len(want) || u.Get(all(a)[0]) == nil {
+			t.Fatalf("degree %d: union without resolve has %d items", degree, u.Len())
+		}
+	}
+	a := NewReverse(3)
+	for _, v := range perm(10) {
+		a.ReplaceOrInsert(v)
+	}
+	if got, want := all(Union(a, New(2), nil)), all(a); !reflect.DeepEqual(got, want) {
+		t.Fatalf("reverse: got %v, want %v", got, want)
+	}
+}
+
+func TestIntersectDifference(t *testing.T) {
+	for _, degree := range []int{2, 3, 8} {
+		a, ka := randomSet(degree, 500, 0.5)
+		b, kb := randomSet(degree, 500, 0.5)
+		var wantI, wantD []*Item
+		for k := 0; k < 500; k++ {
+			if !ka[k] {
+				continue
+			}
+			if kb[k] {
+				wantI = append(wantI, a.Get(createItem(k)))
+			} else {
+				wantD = append(wantD, a.Get(createItem(k)))
+			}
+		}
+		in, diff := Intersect(a, b), Difference(a, b)
+		checkTree(t, in)
+		checkTree(t, diff)
+		if got := all(in); !reflect.DeepEqual(got, wantI) {
+			t.Fatalf("degree %d: intersection:\n got: %v\nwant: %v", degree, got, wantI)
+		}
+		if got := all(diff); !reflect.DeepEqual(got, wantD) {
+			t.Fatalf("degree %d: difference:\n got: %v\nwant: %v", degree, got, wantD)
+		}
+		if Intersect(a, New(2)).Len() != 0 || Difference(a, a).Len() != 0 || Difference(a, New(2)).Len() != a.Len() {
+			t.Fatalf("degree %d: wrong results against empty tree or itself", degree)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	for _, degree := range []int{2, 3, 8} {
+		a := New(degree)
+		for _, v := range perm(1000) {
+			a.ReplaceOrInsert(v)
+		}
+		b := a.Clone()
+		if !Equal(a, b) || Compare(a, b) != 0 {
+			t.Fatalf("degree %d: clone differs", degree)
+		}
+		for i := 0; i < 100; i++ {
+			k := rand.Intn(1000)
+			b.Delete(createItem(k))
+			// a holds k where b holds the item after it, if any.
+			want := -1
+			if k == 999 {
+				want = 1
+			}
+			if Equal(a, b) || Compare(a, b) != want || Compare(b, a) != -want {
+				t.Fatalf("degree %d: deleting %d: compare %d", degree, k, Compare(a, b))
+			}
+			b.ReplaceOrInsert(createItem(k))
+			if !Equal(a, b) || Compare(b, a) != 0 {
+				t.Fatalf("degree %d: reinserting %d: compare %d", degree, k, Compare(a, b))
+			}
+		}
+		b.ReplaceOrInsert(createItem(1000))
+		if Equal(a, b) || Compare(a, b) != -1 || Compare(b, a) != 1 {
+			t.Fatalf("degree %d: prefix compares %d", degree, Compare(a, b))
+		}
+		if !Equal(New(2), New(3)) || Compare(New(2), a) != -1 {
+			t.Fatalf("degree %d: empty trees", degree)
+		}
+	}
+}
+
+func BenchmarkCompareClone(b *testing.B) {
+	x := New(*btreeDegree)
+	for _, v := range perm(benchmarkTreeSize) {
+		x.ReplaceOrInsert(v)
+	}
+	y := x.Clone()
+	y.Delete(createItem(benchmarkTreeSize / 2))
+	y.ReplaceOrInsert(createItem(benchmarkTreeSize / 2))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !Equal(x, y) {
+			b.Fatal("clone differs")
+		}
+	}
+}
+
+func BenchmarkUnion(b *testing.B) {
+	x, _ := randomSet(*btreeDegree, benchmarkTreeSize, 0.5)
+	y, _ := randomSet(*btreeDegree, benchmarkTreeSize, 0.5)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Union(x, y, nil)
+	}
+}
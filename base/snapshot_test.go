@@ -0,0 +1,173 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// countingWriter counts the writes made to it.
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestSnapshot(t *testing.T) {
+	multi := NewMulti(2)
+	for _, v := range perm(100) {
+		multi.ReplaceOrInsert(v)
+		multi.ReplaceOrInsert(createItem(int(v.Key)))
+	}
+	for _, tr := range []*BTree{New(2), New(32), NewReverse(3), multi} {
+		if tr != multi {
+			for _, v := range perm(1000) {
+				tr.ReplaceOrInsert(v)
+			}
+		}
+		for _, chunkSize := range []int{0, 1, 100, 1 << 20} {
+			var w countingWriter
+			if err := tr.Snapshot(&w, chunkSize); err != nil {
+				t.Fatal(err)
+			}
+			// The snapshot is followed by other data, which Restore must not
+			// consume.
+			w.WriteString("rest")
+			got := New(5)
+			if err := got.Restore(&w.Buffer); err != nil {
+				t.Fatalf("chunk size %d: %v", chunkSize, err)
+			}
+			checkTree(t, got)
+			if got.degree != tr.degree || got.reverse != tr.reverse || got.multi != tr.multi {
+				t.Fatalf("got degree %d, reverse %v, multi %v", got.degree, got.reverse, got.multi)
+			}
+			if !reflect.DeepEqual(all(got), all(tr)) {
+				t.Fatalf("chunk size %d: mismatch:\n got: %v\nwant: %v", chunkSize, all(got), all(tr))
+			}
+			if rest := w.String(); rest != "rest" {
+				t.Fatalf("chunk size %d: Restore left %q", chunkSize, rest)
+			}
+			if chunkSize == 1 && w.writes < 3*tr.Len() {
+				t.Fatalf("chunk size 1: %d writes for %d items", w.writes, tr.Len())
+			}
+		}
+	}
+}
+
+func TestSnapshotEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := New(4).Snapshot(&buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	tr := New(2)
+	tr.ReplaceOrInsert(createItem(1))
+	if err := tr.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if tr.Len() != 0 || tr.degree != 4 {
+		t.Fatalf("got len %d, degree %d", tr.Len(), tr.degree)
+	}
+}
+
+// failingWriter fails after n bytes.
+type failingWriter struct {
+	n int
+}
+
+var errFailingWriter = errors.New("failing writer")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		return 0, errFailingWriter
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestSnapshotErrors(t *testing.T) {
+	tr := New(3)
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(v)
+	}
+	for _, n := range []int{0, 10, 100, 500} {
+		if err := tr.Snapshot(&failingWriter{n}, 64); err != errFailingWriter {
+			t.Errorf("failing after %d bytes: got %v", n, err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := tr.Snapshot(&buf, 64); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	flipped := append([]byte(nil), data...)
+	flipped[len(flipped)/2] ^= 1
+	// The header is checksummed too.
+	reversed := append([]byte(nil), data...)
+	reversed[1] ^= binaryReverse
+	// A corrupt length must not make Restore allocate anywhere near that
+	// much before finding the data missing.
+	header := len(New(3).appendHeader(nil)) + 4
+	huge := append([]byte(nil), data[:header]...)
+	huge = append(huge, 0xff, 0xff, 0xff, 0xf0, 1, 2, 3)
+	for name, test := range map[string]struct {
+		data []byte
+		err  error
+	}{
+		"empty":     {nil, ErrBadEncoding},
+		"header":    {data[:3], ErrBadEncoding},
+		"truncated": {data[:len(data)-1], ErrBadEncoding},
+		"flipped":   {flipped, ErrChecksum},
+		"reversed":  {reversed, ErrChecksum},
+		"huge":      {huge, ErrBadEncoding},
+	} {
+		got := New(2)
+		got.ReplaceOrInsert(createItem(42))
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		if err := got.Restore(bytes.NewReader(test.data)); err != test.err {
+			t.Errorf("%s: got %v, want %v", name, err, test.err)
+		}
+		runtime.ReadMemStats(&after)
+		if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 1<<20 {
+			t.Errorf("%s: allocated %d bytes", name, alloc)
+		}
+		if got.Len() != 1 {
+			t.Errorf("%s: failed restore modified the tree", name)
+		}
+	}
+}
+
+func BenchmarkSnapshot(b *testing.B) {
+	tr := New(*btreeDegree)
+	for _, v := range perm(benchmarkTreeSize) {
+		tr.ReplaceOrInsert(v)
+	}
+	var buf bytes.Buffer
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := tr.Snapshot(&buf, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
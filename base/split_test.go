@@ -0,0 +1,254 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// checkTree fails the test if tr violates any of the B-Tree invariants.
+func checkTree(t *testing.T, tr *BTree) {
+	t.Helper()
+	if err := tr.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeleteRange(t *testing.T) {
+	const treeSize = 1000
+	for _, degree := range []int{2, 3, 4, 8, 32} {
+		for iter := 0; iter < 50; iter++ {
+			tr := New(degree)
+			for _, v := range perm(treeSize) {
+				tr.ReplaceOrInsert(v)
+			}
+			clone := tr.Clone()
+			lo, hi := rand.Intn(treeSize+20)-10, rand.Intn(treeSize+20)-10
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			var want []*Item
+			for _, item := range rang(treeSize) {
+				if int(item.Key) < lo || int(item.Key) >= hi {
+					want = append(want, item)
+				}
+			}
+			removed := tr.DeleteRange(createItem(lo), createItem(hi))
+			if removed != treeSize-len(want) {
+				t.Fatalf("degree %d, [%d, %d): removed %d, want %d", degree, lo, hi, removed, treeSize-len(want))
+			}
+			checkTree(t, tr)
+			if got := all(tr); !reflect.DeepEqual(got, want) {
+				t.Fatalf("degree %d, [%d, %d): mismatch:\n got: %v\nwant: %v", degree, lo, hi, got, want)
+			}
+			if got := all(clone); !reflect.DeepEqual(got, rang(treeSize)) {
+				t.Fatalf("degree %d, [%d, %d): clone was modified", degree, lo, hi)
+			}
+		}
+	}
+}
+
+func TestDeleteRangeOpenBounds(t *testing.T) {
+	tr := New(3)
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(v)
+	}
+	if got := tr.DeleteRange(nil, createItem(10)); got != 10 {
+		t.Fatalf("removed %d, want 10", got)
+	}
+	if got := tr.DeleteRange(createItem(90), nil); got != 10 {
+		t.Fatalf("removed %d, want 10", got)
+	}
+	checkTree(t, tr)
+	if got, want := all(tr), rang(90)[10:]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("mismatch:\n got: %v\nwant: %v", got, want)
+	}
+	if got := tr.DeleteRange(createItem(50), createItem(50)); got != 0 {
+		t.Fatalf("empty range removed %d", got)
+	}
+	if got := tr.DeleteRange(nil, nil); got != 80 {
+		t.Fatalf("removed %d, want 80", got)
+	}
+	if tr.Len() != 0 || tr.Min() != nil {
+		t.Fatalf("tree not empty after removing everything")
+	}
+	tr.ReplaceOrInsert(createItem(1))
+	checkTree(t, tr)
+}
+
+func BenchmarkDeleteRange(b *testing.B) {
+	insertP := perm(benchmarkTreeSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		tr := New(*btreeDegree)
+		for _, item := range insertP {
+			tr.ReplaceOrInsert(item)
+		}
+		b.StartTimer()
+		tr.DeleteRange(createItem(benchmarkTreeSize/4), createItem(benchmarkTreeSize/2))
+	}
+}
+
+func TestDeleteMinMaxN(t *testing.T) {
+	for _, tr := range []*BTree{New(3), NewMulti(3), NewReverse(3)} {
+		for _, v := range perm(100) {
+			tr.ReplaceOrInsert(v)
+		}
+		want := all(tr)
+		if got := tr.DeleteMinN(10); !reflect.DeepEqual(got, want[:10]) {
+			t.Fatalf("min 10:\n got: %v\nwant: %v", got, want[:10])
+		}
+		got := tr.DeleteMaxN(20)
+		for i := 0; i < 20; i++ {
+			if got[i] != want[99-i] {
+				t.Fatalf("max 20: got %v at %d, want %v", got[i], i, want[99-i])
+			}
+		}
+		checkTree(t, tr)
+		if got := all(tr); !reflect.DeepEqual(got, want[10:80]) {
+			t.Fatalf("left:\n got: %v\nwant: %v", got, want[10:80])
+		}
+		if got := tr.DeleteMinN(0); got != nil {
+			t.Fatalf("min 0: got %v", got)
+		}
+		if got := tr.DeleteMinN(1000); !reflect.DeepEqual(got, want[10:80]) || tr.Len() != 0 {
+			t.Fatalf("min 1000: got %v, %d left", got, tr.Len())
+		}
+		if got := tr.DeleteMaxN(1); got != nil {
+			t.Fatalf("max of empty tree: got %v", got)
+		}
+	}
+	// Equal items straddling the cut stay in the tree.
+	tr := NewMulti(2)
+	for i := 0; i < 10; i++ {
+		tr.ReplaceOrInsert(&Item{Key: KeyType(i / 5), Payload: i})
+	}
+	got := tr.DeleteMinN(3)
+	if len(got) != 3 || got[2].Payload != 2 || tr.Len() != 7 || tr.Min().Payload != 3 {
+		t.Fatalf("multi: got %v, min %v, len %d", got, tr.Min(), tr.Len())
+	}
+}
+
+func BenchmarkDeleteMinN(b *testing.B) {
+	insertP := perm(benchmarkTreeSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		tr := New(*btreeDegree)
+		for _, item := range insertP {
+			tr.ReplaceOrInsert(item)
+		}
+		b.StartTimer()
+		for tr.Len() > 0 {
+			tr.DeleteMinN(100)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	const treeSize = 1000
+	for _, degree := range []int{2, 3, 4, 8, 32} {
+		for iter := 0; iter < 50; iter++ {
+			tr := New(degree)
+			for _, v := range perm(treeSize) {
+				tr.ReplaceOrInsert(v)
+			}
+			key := rand.Intn(treeSize+20) - 10
+			left, right := tr.Split(createItem(key))
+			checkTree(t, left)
+			checkTree(t, right)
+			split := key
+			if split < 0 {
+				split = 0
+			} else if split > treeSize {
+				split = treeSize
+			}
+			want := rang(treeSize)
+			wantLeft := append([]*Item(nil), want[:split]...)
+			wantRight := append([]*Item(nil), want[split:]...)
+			if got := all(left); !reflect.DeepEqual(got, wantLeft) {
+				t.Fatalf("degree %d, key %d: left mismatch:\n got: %v\nwant: %v", degree, key, got, wantLeft)
+			}
+			if got := all(right); !reflect.DeepEqual(got, wantRight) {
+				t.Fatalf("degree %d, key %d: right mismatch:\n got: %v\nwant: %v", degree, key, got, wantRight)
+			}
+			if got := all(tr); !reflect.DeepEqual(got, want) {
+				t.Fatalf("degree %d, key %d: original was modified", degree, key)
+			}
+			// Writes to either half must not leak into the other or the original.
+			left.ReplaceOrInsert(createItem(treeSize + 1))
+			right.ReplaceOrInsert(createItem(-1))
+			if tr.Len() != treeSize || tr.Has(createItem(-1)) || tr.Has(createItem(treeSize+1)) {
+				t.Fatalf("degree %d, key %d: writes to halves leaked into original", degree, key)
+			}
+			left.Delete(createItem(treeSize + 1))
+			right.Delete(createItem(-1))
+			if err := left.Merge(right); err != nil {
+				t.Fatalf("degree %d, key %d: merge: %v", degree, key, err)
+			}
+			checkTree(t, left)
+			if got := all(left); !reflect.DeepEqual(got, want) {
+				t.Fatalf("degree %d, key %d: merged mismatch:\n got: %v\nwant: %v", degree, key, got, want)
+			}
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a, b := New(3), New(3)
+	for _, v := range perm(100) {
+		a.ReplaceOrInsert(v)
+		b.ReplaceOrInsert(createItem(int(v.Key) + 50))
+	}
+	if err := a.Merge(b); err != ErrOverlap {
+		t.Fatalf("merging overlapping trees: got %v, want ErrOverlap", err)
+	}
+	if a.Len() != 100 {
+		t.Fatalf("failed merge modified tree, len %d", a.Len())
+	}
+	c := New(3)
+	for _, v := range perm(300) {
+		c.ReplaceOrInsert(createItem(int(v.Key) + 100))
+	}
+	// Merge the right-hand tree first and the left-hand one second, so both
+	// join directions are exercised.
+	d := New(3)
+	if err := d.Merge(c); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Merge(a); err != nil {
+		t.Fatal(err)
+	}
+	checkTree(t, d)
+	if got, want := all(d), rang(400); !reflect.DeepEqual(got, want) {
+		t.Fatalf("mismatch:\n got: %v\nwant: %v", got, want)
+	}
+	if a.Len() != 100 || c.Len() != 300 {
+		t.Fatalf("merged trees were modified")
+	}
+	// Trees of different degree fall back to inserting items.
+	e := New(5)
+	e.ReplaceOrInsert(createItem(400))
+	if err := d.Merge(e); err != nil {
+		t.Fatal(err)
+	}
+	checkTree(t, d)
+	if got, want := all(d), rang(401); !reflect.DeepEqual(got, want) {
+		t.Fatalf("mismatch:\n got: %v\nwant: %v", got, want)
+	}
+}
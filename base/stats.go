@@ -0,0 +1,218 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+const (
+	nodeBytes    = int(unsafe.Sizeof(node{}))
+	pointerBytes = int(unsafe.Sizeof(uintptr(0)))
+)
+
+// Stats describes the shape of a tree, as returned by BTree.Stats.
+type Stats struct {
+	Items  int // number of items
+	Nodes  int // number of nodes, including leaves
+	Leaves int // number of leaf nodes
+	Depth  int // number of levels of nodes, 0 for an empty tree
+	// FillFactor is the average number of items per node relative to the
+	// maximum, between 0 and 1.  Low values after many deletions indicate
+	// fragmentation.
+	FillFactor float64
+	// Bytes estimates the memory used by the nodes of the tree, including the
+	// backing arrays of their item and child slices but not the items
+	// themselves.
+	Bytes int
+}
+
+// Stats walks the whole tree and reports its shape.
+func (t *BTree) Stats() Stats {
+	var s Stats
+	if t.root == nil {
+		return s
+	}
+	t.root.stats(&s)
+	s.Depth = t.root.height() + 1
+	s.FillFactor = float64(s.Items) / float64(s.Nodes*t.maxItems())
+	return s
+}
+
+func (n *node) stats(s *Stats) {
+	s.Items += len(n.items)
+	s.Nodes++
+	s.Bytes += n.bytes()
+	if len(n.children) == 0 {
+		s.Leaves++
+	}
+	for _, c := range n.children {
+		c.stats(s)
+	}
+}
+
+// bytes estimates the memory used by the node itself.
+func (n *node) bytes() int {
+	return nodeBytes + (cap(n.items)+cap(n.children))*pointerBytes
+}
+
+// MemoryUsage describes the memory used by the nodes of a tree, as returned
+// by BTree.MemoryUsage.  Like Stats.Bytes, it counts nodes and the backing
+// arrays of their slices but not the items themselves.
+type MemoryUsage struct {
+	// Exclusive is the memory used by nodes that belong to this tree alone,
+	// which are the ones it has written to since it was last cloned.
+	Exclusive int
+	// Shared is the memory used by nodes that the tree may share
+	// copy-on-write with clones.  Freeing the tree does not release them
+	// while any of those clones is still alive.
+	Shared int
+}
+
+// MemoryUsage walks the tree and reports how much of its memory it owns and
+// how much it may share with clones.
+//
+// A node belongs to a tree when it was created or copied by that tree's
+// current copy-on-write context; since Clone gives both trees new contexts,
+// every node of a freshly cloned tree counts as shared.  The nodes below a
+// shared node are always shared as well, so they are summed without
+// inspecting their owners.
+func (t *BTree) MemoryUsage() MemoryUsage {
+	var m MemoryUsage
+	if t.root != nil {
+		t.root.memoryUsage(t.cow, &m)
+	}
+	return m
+}
+
+func (n *node) memoryUsage(c *copyOnWriteContext, m *MemoryUsage) {
+	if n.cow != c {
+		var s Stats
+		n.stats(&s)
+		m.Shared += s.Bytes
+		return
+	}
+	m.Exclusive += n.bytes()
+	for _, child := range n.children {
+		child.memoryUsage(c, m)
+	}
+}
+
+// TotalMemoryUsage returns the memory used by the nodes of all the given
+// trees together, counting each node shared between them only once.  This is
+// what a set of clones actually costs, whereas summing their Stats.Bytes
+// counts shared nodes once per tree.
+func TotalMemoryUsage(trees ...*BTree) int {
+	seen := make(map[*node]bool)
+	total := 0
+	for _, t := range trees {
+		if t.root != nil {
+			total += t.root.distinctBytes(seen)
+		}
+	}
+	return total
+}
+
+// distinctBytes sums the memory of the nodes in the subtree rooted at n that
+// are not yet in seen, adding them to it.  Subtrees of nodes already seen are
+// skipped, as they were counted along with that node.
+func (n *node) distinctBytes(seen map[*node]bool) int {
+	if seen[n] {
+		return 0
+	}
+	seen[n] = true
+	out := n.bytes()
+	for _, c := range n.children {
+		out += c.distinctBytes(seen)
+	}
+	return out
+}
+
+// CheckInvariants walks the whole tree and returns an error describing the
+// first violation of the B-Tree invariants it finds: items out of order
+// (which mutating keys of items in the tree causes), nodes with too few or too
+// many items or children, leaves at different depths, or a length that does
+// not match the number of items.
+func (t *BTree) CheckInvariants() error {
+	if t.root == nil {
+		if t.length != 0 {
+			return fmt.Errorf("btree: empty tree has length %d", t.length)
+		}
+		if t.first != nil || t.last != nil {
+			return fmt.Errorf("btree: empty tree has cached bounds %v, %v", t.first, t.last)
+		}
+		return nil
+	}
+	count := 0
+	if err := t.root.check(t, 0, t.root.height(), nil, nil, &count); err != nil {
+		return err
+	}
+	if count != t.length {
+		return fmt.Errorf("btree: tree holds %d items but has length %d", count, t.length)
+	}
+	if t.first != min(t.root) || t.last != max(t.root) {
+		return fmt.Errorf("btree: cached bounds %v, %v do not match the tree", t.first, t.last)
+	}
+	return nil
+}
+
+// check verifies the subtree rooted at n, which must be at the given depth and
+// height and hold items within [lo, hi] (nil bounds are open).
+func (n *node) check(t *BTree, depth, height int, lo, hi *Item, count *int) error {
+	*count += len(n.items)
+	if depth > 0 && len(n.items) < t.minItems() {
+		return fmt.Errorf("btree: node at depth %d has %d items, minimum is %d", depth, len(n.items), t.minItems())
+	}
+	if len(n.items) > t.maxItems() {
+		return fmt.Errorf("btree: node at depth %d has %d items, maximum is %d", depth, len(n.items), t.maxItems())
+	}
+	for i, item := range n.items {
+		if item == nil {
+			return fmt.Errorf("btree: nil item at depth %d", depth)
+		}
+		if (lo != nil && item.Less(lo)) || (hi != nil && hi.Less(item)) {
+			return fmt.Errorf("btree: item %v at depth %d is out of order with its ancestors", item.Key, depth)
+		}
+		if i > 0 && item.Less(n.items[i-1]) {
+			return fmt.Errorf("btree: item %v at depth %d is out of order with %v", item.Key, depth, n.items[i-1].Key)
+		}
+		if i > 0 && !t.multi && !n.items[i-1].Less(item) {
+			return fmt.Errorf("btree: duplicate item %v at depth %d", item.Key, depth)
+		}
+	}
+	if len(n.children) == 0 {
+		if depth != height {
+			return fmt.Errorf("btree: leaf at depth %d, expected all leaves at depth %d", depth, height)
+		}
+		return nil
+	}
+	if len(n.children) != len(n.items)+1 {
+		return fmt.Errorf("btree: node at depth %d has %d items but %d children", depth, len(n.items), len(n.children))
+	}
+	for i, c := range n.children {
+		clo, chi := lo, hi
+		if i > 0 {
+			clo = n.items[i-1]
+		}
+		if i < len(n.items) {
+			chi = n.items[i]
+		}
+		if err := c.check(t, depth+1, height, clo, chi, count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
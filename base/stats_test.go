@@ -0,0 +1,97 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import "testing"
+
+func TestStats(t *testing.T) {
+	tr := New(2)
+	if s := tr.Stats(); s != (Stats{}) {
+		t.Fatalf("empty tree: %+v", s)
+	}
+	tr.InsertBatch(rang(15))
+	s := tr.Stats()
+	// 15 items packed into a degree-2 tree fill 5 nodes of 3 items each.
+	if s.Items != 15 || s.Nodes != 5 || s.Leaves != 4 || s.Depth != 2 || s.FillFactor != 1 {
+		t.Fatalf("packed tree: %+v", s)
+	}
+	if s.Bytes < s.Nodes*nodeBytes {
+		t.Fatalf("packed tree: %d bytes for %d nodes", s.Bytes, s.Nodes)
+	}
+	for _, v := range perm(15) {
+		tr.Delete(v)
+		if err := tr.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCheckInvariants(t *testing.T) {
+	tr := New(2)
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(v)
+	}
+	if err := tr.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	// Mutating a key in place breaks the ordering.
+	item := tr.Get(createItem(50))
+	item.Key = 1000
+	if err := tr.CheckInvariants(); err == nil {
+		t.Fatal("mutated key not detected")
+	}
+	item.Key = 50
+	tr.length++
+	if err := tr.CheckInvariants(); err == nil {
+		t.Fatal("wrong length not detected")
+	}
+	tr.length--
+	leaf := tr.root
+	for len(leaf.children) > 0 {
+		leaf = leaf.children[0]
+	}
+	leaf.items = leaf.items[:0]
+	if err := tr.CheckInvariants(); err == nil {
+		t.Fatal("underfull node not detected")
+	}
+}
+
+func TestMemoryUsage(t *testing.T) {
+	tr := New(2)
+	for _, v := range perm(1000) {
+		tr.ReplaceOrInsert(v)
+	}
+	total := tr.Stats().Bytes
+	if m := tr.MemoryUsage(); m.Exclusive != total || m.Shared != 0 {
+		t.Fatalf("unshared tree: %+v, want %d exclusive", m, total)
+	}
+	clone := tr.Clone()
+	if m := tr.MemoryUsage(); m.Exclusive != 0 || m.Shared != total {
+		t.Fatalf("fresh clone: %+v, want %d shared", m, total)
+	}
+	if got := TotalMemoryUsage(tr, clone); got != total {
+		t.Fatalf("total of fresh clones: %d, want %d", got, total)
+	}
+	// A write copies the path to one leaf, so the clone now owns a few
+	// nodes and shares the rest.
+	clone.ReplaceOrInsert(createItem(1000))
+	m := clone.MemoryUsage()
+	if m.Exclusive == 0 || m.Shared == 0 || m.Exclusive+m.Shared != clone.Stats().Bytes {
+		t.Fatalf("written clone: %+v, total %d", m, clone.Stats().Bytes)
+	}
+	if got := TotalMemoryUsage(tr, clone); got != total+m.Exclusive {
+		t.Fatalf("total after write: %d, want %d", got, total+m.Exclusive)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestThrottle(t *testing.T) {
+	tr := New(3)
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(v)
+	}
+	th := NewThrottle(1000, 0, nil)
+	var got []*Item
+	start := time.Now()
+	tr.Ascend(th.Iterator(func(i *Item) bool {
+		got = append(got, i)
+		return true
+	}))
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Fatalf("100 items at 1000/s took %v", elapsed)
+	}
+	if want := rang(100); !reflect.DeepEqual(got, want) {
+		t.Fatalf("mismatch:\n got: %v\nwant: %v", got, want)
+	}
+
+	th = NewThrottle(0, 10000, func(*Item) int { return 100 })
+	start = time.Now()
+	tr.AscendLessThan(createItem(50), th.Iterator(func(i *Item) bool { return true }))
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Fatalf("5000 bytes at 10000/s took %v", elapsed)
+	}
+}
+
+func TestThrottleHighRate(t *testing.T) {
+	// At 2e9 items per second, an item costs half a nanosecond, which
+	// must add up rather than round down to nothing.
+	th := NewThrottle(2e9, 0, nil)
+	now := time.Now()
+	var delay time.Duration
+	for i := 0; i < 2000; i++ {
+		delay, _ = th.reserve(now, th.itemCost)
+	}
+	if delay != time.Microsecond {
+		t.Fatalf("2000 items at 2e9/s reserved %v, want 1µs", delay)
+	}
+}
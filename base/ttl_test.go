@@ -0,0 +1,56 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExpiringTree(t *testing.T) {
+	tr := NewExpiring(*btreeDegree)
+	for _, v := range perm(100) {
+		tr.InsertWithTTL(v, time.Duration(v.Key)*time.Minute)
+	}
+	tr.ReplaceOrInsert(createItem(100))
+	// Item 10 is deleted and item 20 replaced, without a deadline, before
+	// they expire; item 30 gets a later deadline.
+	tr.Delete(createItem(10))
+	tr.ReplaceOrInsert(createItem(20))
+	tr.InsertWithTTL(createItem(30), time.Hour*24)
+
+	now := time.Now()
+	if got := tr.ExpireBefore(now.Add(-time.Hour)); got != 0 {
+		t.Fatalf("removed %d items before any expired", got)
+	}
+	if got := tr.ExpireBefore(now.Add(50 * time.Minute)); got != 48 {
+		t.Fatalf("removed %d items, want 48", got)
+	}
+	checkTree(t, tr.BTree)
+	want := append([]*Item{createItem(20), createItem(30)}, rang(101)[51:]...)
+	if got := all(tr.BTree); !reflect.DeepEqual(got, want) {
+		t.Fatalf("mismatch:\n got: %v\nwant: %v", got, want)
+	}
+	if got := tr.ExpireBefore(now.Add(48 * time.Hour)); got != 50 {
+		t.Fatalf("removed %d items, want 50", got)
+	}
+	if got := all(tr.BTree); !reflect.DeepEqual(got, []*Item{createItem(20), createItem(100)}) {
+		t.Fatalf("items without deadline removed: %v", got)
+	}
+	if len(tr.expiries) != 0 || len(tr.deadlines) != 0 {
+		t.Fatalf("%d expiries and %d deadlines left", len(tr.expiries), len(tr.deadlines))
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTxn(t *testing.T) {
+	tr := New(*btreeDegree)
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(v)
+	}
+	txn := tr.Begin()
+	txn.DeleteRange(createItem(50), nil)
+	txn.ReplaceOrInsert(createItem(1000))
+	if !txn.Has(createItem(1000)) || txn.Has(createItem(50)) {
+		t.Fatal("transaction does not see its own writes")
+	}
+	if tr.Has(createItem(1000)) || tr.Len() != 100 {
+		t.Fatal("uncommitted writes visible in the parent")
+	}
+	if err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	checkTree(t, tr)
+	if got, want := all(tr), append(rang(50), createItem(1000)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("mismatch:\n got: %v\nwant: %v", got, want)
+	}
+	if err := txn.Commit(); err != ErrTxnDone {
+		t.Fatalf("second commit: got %v", err)
+	}
+	// Writes through a committed transaction must not reach the parent.
+	txn.ReplaceOrInsert(createItem(3000))
+	txn.Delete(createItem(0))
+	txn.DeleteRange(nil, nil)
+	checkTree(t, tr)
+	if got, want := all(tr), append(rang(50), createItem(1000)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("writes after commit leaked:\n got: %v\nwant: %v", got, want)
+	}
+
+	txn = tr.Begin()
+	txn.Delete(createItem(0))
+	txn.Rollback()
+	if tr.Len() != 51 || !tr.Has(createItem(0)) {
+		t.Fatal("rolled back writes visible in the parent")
+	}
+	if err := txn.Commit(); err != ErrTxnDone {
+		t.Fatalf("commit after rollback: got %v", err)
+	}
+
+	txn = tr.Begin()
+	txn.Delete(createItem(0))
+	tr.ReplaceOrInsert(createItem(2000))
+	if err := txn.Commit(); err != ErrTxnConflict {
+		t.Fatalf("conflicting commit: got %v", err)
+	}
+	if tr.Len() != 52 || !tr.Has(createItem(0)) {
+		t.Fatal("conflicting commit modified the parent")
+	}
+	txn.Rollback()
+}
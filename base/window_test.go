@@ -0,0 +1,69 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCountWindow(t *testing.T) {
+	w := NewCountWindow(*btreeDegree, 10)
+	for i, v := range rang(100) {
+		want := 0
+		if i >= 10 {
+			want = 1
+		}
+		if got := w.Append(v); got != want {
+			t.Fatalf("append %v: evicted %d, want %d", v, got, want)
+		}
+	}
+	checkTree(t, w.BTree)
+	if got, want := all(w.BTree), rang(100)[90:]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("mismatch:\n got: %v\nwant: %v", got, want)
+	}
+	if got := w.Append(createItem(5)); got != 1 || w.Has(createItem(5)) {
+		t.Fatalf("stale item: evicted %d, kept %v", got, w.Has(createItem(5)))
+	}
+}
+
+func TestKeyWindow(t *testing.T) {
+	w := NewKeyWindow(*btreeDegree, func(newest *Item) *Item {
+		return &Item{Key: newest.Key - 20}
+	})
+	for _, v := range rang(100) {
+		w.Append(v)
+	}
+	if got := w.Append(createItem(100)); got != 1 {
+		t.Fatalf("evicted %d, want 1", got)
+	}
+	// A jump in the keys evicts many items at once.
+	if got := w.Append(createItem(110)); got != 10 {
+		t.Fatalf("evicted %d, want 10", got)
+	}
+	checkTree(t, w.BTree)
+	want := append(rang(100)[90:], createItem(100), createItem(110))
+	if got := all(w.BTree); !reflect.DeepEqual(got, want) {
+		t.Fatalf("mismatch:\n got: %v\nwant: %v", got, want)
+	}
+}
+
+func BenchmarkWindowAppend(b *testing.B) {
+	w := NewCountWindow(*btreeDegree, benchmarkTreeSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.Append(createItem(i))
+	}
+}
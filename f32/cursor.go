@@ -0,0 +1,141 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package f32
+
+// Cursor remembers the path from the root to where the last AscendFromHint
+// that used it started, as a hint for the next one.  The zero Cursor is an
+// empty hint.
+//
+// A hint is checked before it is used, so a Cursor stays safe to use after
+// the tree is modified, or with another tree; a stale hint merely costs a
+// full descent.  It does keep the nodes on its path from being freed.
+type Cursor struct {
+	path []cursorFrame
+}
+
+// cursorFrame is a node on the path of a Cursor, with the index of the child
+// the path goes on to, or for a leaf, of the item it ends at.  lo and hi are
+// the items bounding the keys of the node's subtree, nil where the subtree is
+// open-ended.
+type cursorFrame struct {
+	n      *node
+	i      int
+	lo, hi *Item
+}
+
+// AscendFromHint calls the iterator for every value in the tree within the
+// range [pivot, last], until iterator returns false, as AscendGreaterOrEqual
+// does.  The search for pivot starts from the deepest node on the path of the
+// hint whose range still holds pivot, and the hint is then moved to pivot.
+// When consecutive pivots are close, as in merge joins, most of the descent
+// from the root is skipped.
+//
+// Trees created by NewReverse ignore the hint.
+func (t *BTree) AscendFromHint(hint *Cursor, pivot *Item, iterator ItemIterator) {
+	if t.reverse || t.root == nil {
+		hint.path = hint.path[:0]
+		t.AscendGreaterOrEqual(pivot, iterator)
+		return
+	}
+	hint.seek(t.root, pivot)
+	hint.ascend(iterator)
+}
+
+// seek moves the cursor to the first item not less than pivot in the tree
+// rooted at root.
+func (c *Cursor) seek(root *node, pivot *Item) {
+	// Keep the part of the path that is still a path of the tree, refreshing
+	// the bounds of its nodes, which is done without comparing any items.
+	valid := 0
+	for d := range c.path {
+		f := &c.path[d]
+		if d == 0 {
+			if f.n != root {
+				break
+			}
+			f.lo, f.hi = nil, nil
+		} else {
+			p := c.path[d-1]
+			if p.i >= len(p.n.children) || p.n.children[p.i] != f.n {
+				break
+			}
+			f.lo, f.hi = p.bounds()
+		}
+		valid = d + 1
+	}
+	if valid == 0 {
+		c.path = append(c.path[:0], cursorFrame{n: root})
+	} else {
+		// Climb up to the first node whose range holds pivot; the root's
+		// always does.
+		d := valid - 1
+		for ; d > 0; d-- {
+			f := c.path[d]
+			if (f.lo == nil || f.lo.Less(pivot)) && (f.hi == nil || !f.hi.Less(pivot)) {
+				break
+			}
+		}
+		c.path = c.path[:d+1]
+	}
+	for {
+		f := &c.path[len(c.path)-1]
+		f.i = f.n.items.lowerBound(pivot)
+		if len(f.n.children) == 0 {
+			return
+		}
+		lo, hi := f.bounds()
+		c.path = append(c.path, cursorFrame{n: f.n.children[f.i], lo: lo, hi: hi})
+	}
+}
+
+// bounds returns the bounds of the child the frame's path goes on to.
+func (f cursorFrame) bounds() (lo, hi *Item) {
+	lo, hi = f.lo, f.hi
+	if f.i > 0 {
+		lo = f.n.items[f.i-1]
+	}
+	if f.i < len(f.n.items) {
+		hi = f.n.items[f.i]
+	}
+	return
+}
+
+// ascend calls iter for every item from the cursor on, until iter returns
+// false, climbing up the path as each subtree is done.
+func (c *Cursor) ascend(iter ItemIterator) {
+	for d := len(c.path) - 1; d >= 0; d-- {
+		f := c.path[d]
+		if len(f.n.children) == 0 {
+			for _, item := range f.n.items[f.i:] {
+				if !iter(item) {
+					return
+				}
+			}
+			continue
+		}
+		for j := f.i; j < len(f.n.items); j++ {
+			if !iter(f.n.items[j]) {
+				return
+			}
+			if _, ok := f.n.children[j+1].iterate(ascend, nil, nil, false, false, iter); !ok {
+				return
+			}
+		}
+	}
+}
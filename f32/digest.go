@@ -0,0 +1,163 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package f32
+
+import (
+	"math"
+	"reflect"
+	"sync/atomic"
+)
+
+// Hasher is implemented by payloads that take part in RangeDigest.  Items
+// whose payload does not implement it are digested by key only.
+type Hasher interface {
+	Hash64() uint64
+}
+
+// RangeDigest returns a digest of the items in the range [greaterOrEqual,
+// lessThan), where a nil bound leaves that side of the range open.  Two trees
+// holding the same items in a range, with equal keys and equally hashing
+// payloads, give the same digest for it whatever their structure, so replicas
+// can compare digests of ranges and narrow down the ones that differ by
+// splitting them, Merkle-style.
+//
+// Each node caches the digest of its subtree, which writes drop along the
+// path they change.  Nodes in between the bounds are digested whole, so
+// once the cache is warm this takes O(log n) plus the items in the two
+// partially covered nodes per level.  The empty range digests to 0.
+func (t *BTree) RangeDigest(greaterOrEqual, lessThan *Item) uint64 {
+	if t.root == nil {
+		return 0
+	}
+	greaterOrEqual, lessThan, ok := t.bounds(greaterOrEqual, lessThan)
+	if !ok {
+		return 0
+	}
+	return t.root.rangeDigest(greaterOrEqual, lessThan)
+}
+
+// rangeDigest is count for digests: subtrees entirely within the range use
+// their cached digest.
+func (n *node) rangeDigest(greaterOrEqual, lessThan *Item) uint64 {
+	lo, hi := 0, len(n.items)
+	if greaterOrEqual != nil {
+		lo = n.items.lowerBound(greaterOrEqual)
+	}
+	if lessThan != nil {
+		hi = n.items.lowerBound(lessThan)
+	}
+	if hi < lo {
+		return 0
+	}
+	var out uint64
+	for _, item := range n.items[lo:hi] {
+		out += itemDigest(item)
+	}
+	if len(n.children) == 0 {
+		return out
+	}
+	if lo == hi {
+		return out + n.children[lo].rangeDigest(greaterOrEqual, lessThan)
+	}
+	out += n.children[lo].rangeDigest(greaterOrEqual, nil)
+	for _, c := range n.children[lo+1 : hi] {
+		out += c.subtreeDigest()
+	}
+	return out + n.children[hi].rangeDigest(nil, lessThan)
+}
+
+// subtreeDigest returns the digest of all items in the subtree, computing and
+// caching it if needed.
+func (n *node) subtreeDigest() uint64 {
+	if atomic.LoadUint32(&n.digested) == 1 {
+		return atomic.LoadUint64(&n.digest)
+	}
+	var out uint64
+	for _, item := range n.items {
+		out += itemDigest(item)
+	}
+	for _, c := range n.children {
+		out += c.subtreeDigest()
+	}
+	atomic.StoreUint64(&n.digest, out)
+	atomic.StoreUint32(&n.digested, 1)
+	return out
+}
+
+// itemDigest hashes the key of an item and, if it implements Hasher, its
+// payload.  Digests of ranges are sums of item digests, so these are mixed
+// well enough for sums of different items to collide no more often than the
+// digests themselves.
+func itemDigest(item *Item) uint64 {
+	x := keyHash(item.Key)
+	if p, ok := item.Payload.(Hasher); ok {
+		x ^= p.Hash64() * 0x9e3779b97f4a7c15
+	}
+	// The splitmix64 finalizer.
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// keyHash hashes a key without allocating: strings by their bytes and numbers
+// by their bits.  Floating point zeros are hashed alike, as they compare
+// equal.
+func keyHash(key float32) uint64 {
+	switch k := interface{}(key).(type) {
+	case string:
+		h := uint64(14695981039346656037)
+		for i := 0; i < len(k); i++ {
+			h ^= uint64(k[i])
+			h *= 1099511628211
+		}
+		return h
+	case float32:
+		return floatBits(float64(k))
+	case float64:
+		return floatBits(k)
+	case int32:
+		return uint64(k)
+	case int64:
+		return uint64(k)
+	case uint32:
+		return uint64(k)
+	case uint64:
+		return k
+	}
+	// Named numeric types, such as that of this package's own tests.
+	v := reflect.ValueOf(key)
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return floatBits(v.Float())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint64(v.Int())
+	}
+	return v.Uint()
+}
+
+// floatBits returns the bits of f, with -0 turned into 0.
+func floatBits(f float64) uint64 {
+	if f == 0 {
+		return 0
+	}
+	return math.Float64bits(f)
+}
@@ -0,0 +1,193 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package f32
+
+// DualTree orders the same items two ways: by key, and by a value extracted
+// from each item, such as the score of a user in a leaderboard.  Every write
+// updates both orders in the same call, so they never disagree.
+//
+// The value order is kept in a tree created by NewMulti, whose items hold an
+// item's value as Key and the item itself as Payload.  Items with equal values
+// are ordered by when they got that value.  Removing one of them rebuilds the
+// entries for its value, which is cheap as long as values are seldom shared
+// by many items.
+type DualTree struct {
+	byKey   *BTree
+	byValue *BTree
+	value   IndexFunc
+}
+
+// NewDual creates an empty DualTree whose trees have the given degree,
+// ordering items by their key and by the value extracted by value.
+func NewDual(degree int, value IndexFunc) *DualTree {
+	return &DualTree{byKey: New(degree), byValue: NewMulti(degree), value: value}
+}
+
+// Len returns the number of items in the tree.
+func (d *DualTree) Len() int {
+	return d.byKey.Len()
+}
+
+// Get looks for the item with the given key, returning it.  It returns nil if
+// unable to find that item.
+func (d *DualTree) Get(key *Item) *Item {
+	return d.byKey.Get(key)
+}
+
+// ByKey returns the tree of items by key, for reading.  Modifying it directly
+// would leave the value order out of sync.
+func (d *DualTree) ByKey() *BTree {
+	return d.byKey
+}
+
+// ReplaceOrInsert adds the given item to both orders.  If an item with an
+// equal key was already present, it is removed from both and returned.
+// Otherwise, nil is returned.
+func (d *DualTree) ReplaceOrInsert(item *Item) *Item {
+	out := d.byKey.ReplaceOrInsert(item)
+	if out != nil {
+		d.unindex(out)
+	}
+	d.byValue.ReplaceOrInsert(&Item{Key: d.value(item), Payload: item})
+	return out
+}
+
+// Delete removes the item with the given key from both orders, returning it.
+// If no such item exists, returns nil.
+func (d *DualTree) Delete(key *Item) *Item {
+	out := d.byKey.Delete(key)
+	if out != nil {
+		d.unindex(out)
+	}
+	return out
+}
+
+// unindex removes the value entry of item.  The entries of the other items
+// sharing its value keep their order, as DeleteMatch removes just the one.
+func (d *DualTree) unindex(item *Item) {
+	d.byValue.DeleteMatch(&Item{Key: d.value(item)}, func(e *Item) bool {
+		return e.Payload == item
+	})
+}
+
+// AscendValues calls the iterator for every item whose value is within the
+// range [greaterOrEqual, lessThan), in ascending order of value, until
+// iterator returns false.  The bounds hold values as their keys; a nil bound
+// leaves that side of the range open.
+func (d *DualTree) AscendValues(greaterOrEqual, lessThan *Item, iterator ItemIterator) {
+	if d.byValue.root == nil {
+		return
+	}
+	d.byValue.root.iterate(ascend, greaterOrEqual, lessThan, true, false, func(e *Item) bool {
+		return iterator(e.Payload.(*Item))
+	})
+}
+
+// DescendValues calls the iterator for every item whose value is within the
+// range [lessOrEqual, greaterThan), in descending order of value, until
+// iterator returns false.  See AscendValues.
+func (d *DualTree) DescendValues(lessOrEqual, greaterThan *Item, iterator ItemIterator) {
+	if d.byValue.root == nil {
+		return
+	}
+	d.byValue.root.iterate(descend, lessOrEqual, greaterThan, true, false, func(e *Item) bool {
+		return iterator(e.Payload.(*Item))
+	})
+}
+
+// RankOf returns the rank of the item with the given key in descending order
+// of value, counting from 0 for the item with the greatest value, as in a
+// leaderboard.  Among items with equal values, the one that got the value
+// first ranks highest.  It returns -1 if there is no such item.
+//
+// Items with greater values are counted with CountRange, which still visits
+// every node holding them, as nodes keep no counts of their subtrees.  This
+// takes O(log n + above/degree + ties) for above items ranked higher and ties
+// items sharing the value, so it is linear in the rank, if cheaper than
+// walking the items.
+func (d *DualTree) RankOf(key *Item) int {
+	item := d.byKey.Get(key)
+	if item == nil {
+		return -1
+	}
+	value := &Item{Key: d.value(item)}
+	ties := d.byValue.GetAll(value)
+	rank := d.byValue.CountRange(value, nil) - len(ties)
+	for _, e := range ties {
+		if e.Payload == item {
+			break
+		}
+		rank++
+	}
+	return rank
+}
+
+// TopN returns the n items with the greatest values, in descending order of
+// value and ranked as by RankOf, or all items if there are fewer than n.
+func (d *DualTree) TopN(n int) []*Item {
+	return d.ranked(0, n)
+}
+
+// Around returns the items ranked within k places of the item with the given
+// key, including that item, in rank order.  It returns nil if there is no
+// such item.  Like TopN, it walks the items from the top, so it takes time
+// proportional to the rank of the last item returned.
+func (d *DualTree) Around(key *Item, k int) []*Item {
+	rank := d.RankOf(key)
+	if rank < 0 {
+		return nil
+	}
+	from := rank - k
+	if from < 0 {
+		from = 0
+	}
+	return d.ranked(from, rank+k+1-from)
+}
+
+// ranked returns up to n items in rank order, starting from the given rank.
+// Entries are visited in descending order of value, and those sharing a value
+// are buffered so they can be put back in the order they got it.
+func (d *DualTree) ranked(from, n int) []*Item {
+	if n <= 0 || d.byValue.root == nil {
+		return nil
+	}
+	var out, group []*Item
+	rank := 0
+	flush := func() {
+		for i := len(group) - 1; i >= 0 && len(out) < n; i-- {
+			if rank >= from {
+				out = append(out, group[i].Payload.(*Item))
+			}
+			rank++
+		}
+		group = group[:0]
+	}
+	d.byValue.root.iterate(descend, nil, nil, false, false, func(e *Item) bool {
+		if len(group) > 0 && e.Less(group[0]) {
+			flush()
+			if len(out) == n {
+				return false
+			}
+		}
+		group = append(group, e)
+		return true
+	})
+	flush()
+	return out
+}
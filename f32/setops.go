@@ -0,0 +1,201 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package f32
+
+// This file holds operations combining two trees.  They walk both in key order
+// side by side, streaming any resulting tree into a Builder, so they take
+// O(n + m) and never search either tree.
+
+// Union returns a new tree holding the items of both a and b.  Where both
+// hold equal items x and y, the new tree holds resolve(x, y) instead; a nil
+// resolve takes y, as inserting the items of b into a would.
+//
+// The new tree has the degree and order of a.  Both trees must hold distinct
+// items, as trees not created by NewMulti do.  Neither is modified.
+func Union(a, b *BTree, resolve func(x, y *Item) *Item) *BTree {
+	out := NewBuilder(a.degree)
+	merge(a, b, func(x, y *Item) {
+		switch {
+		case x == nil:
+			out.Append(y)
+		case y == nil:
+			out.Append(x)
+		case resolve == nil:
+			out.Append(y)
+		default:
+			out.Append(resolve(x, y))
+		}
+	})
+	return finish(out, a)
+}
+
+// Intersect returns a new tree holding the items of a that b holds an equal
+// item of.  See Union.
+func Intersect(a, b *BTree) *BTree {
+	out := NewBuilder(a.degree)
+	merge(a, b, func(x, y *Item) {
+		if x != nil && y != nil {
+			out.Append(x)
+		}
+	})
+	return finish(out, a)
+}
+
+// Difference returns a new tree holding the items of a that b holds no equal
+// item of.  See Union.
+func Difference(a, b *BTree) *BTree {
+	out := NewBuilder(a.degree)
+	merge(a, b, func(x, y *Item) {
+		if y == nil {
+			out.Append(x)
+		}
+	})
+	return finish(out, a)
+}
+
+// Compare compares the items of a and b lexicographically in ascending key
+// order, returning -1, 0 or 1 as a sorts before, equal to or after b.  Items
+// are compared by Less alone, so payloads are ignored.
+//
+// Subtrees the two trees share, as a tree and its Clone do until either is
+// modified, are skipped whole, so comparing a tree with a recent clone of it
+// only visits the nodes changed since.
+func Compare(a, b *BTree) int {
+	wa, wb := newWalker(a), newWalker(b)
+	for {
+		skipShared(wa, wb)
+		x, y := wa.next(), wb.next()
+		switch {
+		case x == nil && y == nil:
+			return 0
+		case x == nil || y != nil && x.Less(y):
+			return -1
+		case y == nil || y.Less(x):
+			return 1
+		}
+	}
+}
+
+// Equal reports whether a and b hold equal items.  See Compare.
+func Equal(a, b *BTree) bool {
+	return a.Len() == b.Len() && Compare(a, b) == 0
+}
+
+// skipShared skips the subtree both walkers are about to start on, if there
+// is one.  The walkers must have returned equal items so far.
+func skipShared(wa, wb *walker) {
+	ra, rb := wa.fresh(), wb.fresh()
+	for i := ra; i < len(wa.stack); i++ {
+		for j := rb; j < len(wb.stack); j++ {
+			if wa.stack[i].n == wb.stack[j].n {
+				wa.stack, wb.stack = wa.stack[:i], wb.stack[:j]
+				return
+			}
+		}
+	}
+}
+
+// finish returns the tree built by b, in the order of t.
+func finish(b *Builder, t *BTree) *BTree {
+	out := b.Finish()
+	out.reverse = t.reverse
+	return out
+}
+
+// merge walks a and b side by side in ascending key order, calling fn with
+// every item found in only one of them, the other being nil, and with every
+// pair of equal items.
+func merge(a, b *BTree, fn func(x, y *Item)) {
+	ia, ib := newWalker(a), newWalker(b)
+	x, y := ia.next(), ib.next()
+	for x != nil || y != nil {
+		switch {
+		case y == nil || x != nil && x.Less(y):
+			fn(x, nil)
+			x = ia.next()
+		case x == nil || y.Less(x):
+			fn(nil, y)
+			y = ib.next()
+		default:
+			fn(x, y)
+			x, y = ia.next(), ib.next()
+		}
+	}
+}
+
+// walker walks a tree in ascending key order one item at a time, keeping the
+// path to the next item on a stack.
+type walker struct {
+	stack []walkerFrame
+}
+
+// walkerFrame is a node on the path of a walker, with the index of the next
+// of its items to return.
+type walkerFrame struct {
+	n *node
+	i int
+}
+
+func newWalker(t *BTree) *walker {
+	it := &walker{}
+	if t.root != nil {
+		it.descend(t.root)
+	}
+	return it
+}
+
+// descend pushes the path from n down to its first item.
+func (it *walker) descend(n *node) {
+	for {
+		it.stack = append(it.stack, walkerFrame{n: n})
+		if len(n.children) == 0 {
+			return
+		}
+		n = n.children[0]
+	}
+}
+
+// fresh returns the index of the first of the frames at the top of the stack
+// that have not returned any items yet.  Those are the nodes whose subtrees
+// the walker is about to start on.
+func (it *walker) fresh() int {
+	k := len(it.stack)
+	for k > 0 && it.stack[k-1].i == 0 {
+		k--
+	}
+	return k
+}
+
+// next returns the next item, or nil once all have been returned.
+func (it *walker) next() *Item {
+	for len(it.stack) > 0 {
+		f := &it.stack[len(it.stack)-1]
+		if f.i == len(f.n.items) {
+			it.stack = it.stack[:len(it.stack)-1]
+			continue
+		}
+		item := f.n.items[f.i]
+		f.i++
+		if len(f.n.children) > 0 {
+			it.descend(f.n.children[f.i])
+		}
+		return item
+	}
+	return nil
+}
@@ -0,0 +1,384 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package f32
+
+import "errors"
+
+// ErrOverlap is returned by Merge when the key ranges of the two trees
+// overlap.
+var ErrOverlap = errors.New("btree: key ranges overlap")
+
+// This file holds the split and join primitives that whole-range operations
+// are built from.  They work on bare subtrees described by a root node and its
+// height (a leaf has height 0, and a nil root is an empty subtree).  Every
+// subtree passed in or returned is a valid B-Tree except that its root, like
+// the root of a BTree, may hold fewer than minItems items.
+
+// height returns the number of levels below n.
+func (n *node) height() (h int) {
+	for len(n.children) > 0 {
+		n = n.children[0]
+		h++
+	}
+	return
+}
+
+// size returns the number of items in the subtree rooted at n.
+func (n *node) size() int {
+	out := len(n.items)
+	for _, c := range n.children {
+		out += c.size()
+	}
+	return out
+}
+
+// maybeSplit splits n in half if it holds more than maxItems items, returning
+// the separating item and the new right node.
+func maybeSplit(n *node, maxItems int) (*node, *Item, *node) {
+	if len(n.items) <= maxItems {
+		return n, nil, nil
+	}
+	item, second := n.split(len(n.items) / 2)
+	return n, item, second
+}
+
+// join concatenates the subtrees l and r around item, which must sort after
+// everything in l and before everything in r.  Both subtrees are consumed.
+func (c *copyOnWriteContext) join(l *node, lh int, item *Item, r *node, rh int, maxItems int) (*node, int) {
+	if l == nil {
+		l, lh = c.newNode(), 0
+	}
+	if r == nil {
+		r, rh = c.newNode(), 0
+	}
+	var first, second *node
+	var mid *Item
+	h := lh
+	if lh >= rh {
+		first, mid, second = c.joinRight(l, lh, item, r, rh, maxItems)
+	} else {
+		first, mid, second = c.joinLeft(l, lh, item, r, rh, maxItems)
+		h = rh
+	}
+	if second == nil {
+		return first, h
+	}
+	root := c.newNode()
+	root.items = append(root.items, mid)
+	root.children = append(root.children, first, second)
+	return root, h + 1
+}
+
+// joinRight joins r onto the right spine of n, which is at least as tall as r.
+// Should the result overflow, it is split and returned as two nodes around a
+// separating item.
+func (c *copyOnWriteContext) joinRight(n *node, h int, item *Item, r *node, rh int, maxItems int) (*node, *Item, *node) {
+	n = n.mutableFor(c)
+	if h == rh {
+		n.items = append(n.items, item)
+		n.items = append(n.items, r.items...)
+		n.children = append(n.children, r.children...)
+		c.freeNode(r)
+		return maybeSplit(n, maxItems)
+	}
+	last := len(n.children) - 1
+	child, mid, second := c.joinRight(n.children[last], h-1, item, r, rh, maxItems)
+	n.children[last] = child
+	if second != nil {
+		n.items = append(n.items, mid)
+		n.children = append(n.children, second)
+	}
+	return maybeSplit(n, maxItems)
+}
+
+// joinLeft joins l onto the left spine of n, which is taller than l.
+func (c *copyOnWriteContext) joinLeft(l *node, lh int, item *Item, n *node, h int, maxItems int) (*node, *Item, *node) {
+	if h == lh {
+		return c.joinRight(l, lh, item, n, h, maxItems)
+	}
+	n = n.mutableFor(c)
+	child, mid, second := c.joinLeft(l, lh, item, n.children[0], h-1, maxItems)
+	n.children[0] = child
+	if second != nil {
+		n.items.insertAt(0, mid)
+		n.children.insertAt(1, second)
+	}
+	return maybeSplit(n, maxItems)
+}
+
+// concat concatenates the subtrees l and r, where everything in l sorts before
+// everything in r.
+func (c *copyOnWriteContext) concat(l *node, lh int, r *node, rh int, minItems, maxItems int) (*node, int) {
+	if l == nil {
+		return r, rh
+	}
+	if r == nil {
+		return l, lh
+	}
+	r = r.mutableFor(c)
+	item := r.remove(nil, minItems, removeMin)
+	r, rh = c.collapse(r, rh)
+	return c.join(l, lh, item, r, rh, maxItems)
+}
+
+// collapse drops a root left without items by a split or removal, returning
+// the subtree that replaces it.
+func (c *copyOnWriteContext) collapse(n *node, h int) (*node, int) {
+	if len(n.items) > 0 {
+		return n, h
+	}
+	var child *node
+	if len(n.children) > 0 {
+		child = n.children[0]
+	}
+	c.freeNode(n)
+	if child == nil {
+		return nil, 0
+	}
+	return child, h - 1
+}
+
+// splitAt splits the subtree rooted at n into the subtrees holding the items
+// less than key and the items greater than or equal to key.  The original
+// subtree is consumed.
+func (c *copyOnWriteContext) splitAt(n *node, h int, key *Item, maxItems int) (l *node, lh int, r *node, rh int) {
+	n = n.mutableFor(c)
+	i := n.items.lowerBound(key)
+	if len(n.children) == 0 {
+		r = c.newNode()
+		r.items = append(r.items, n.items[i:]...)
+		n.items.truncate(i)
+		l, lh = c.collapse(n, 0)
+		r, rh = c.collapse(r, 0)
+		return
+	}
+	child := n.children[i]
+	// Everything right of the child forms its own subtree, joined to the
+	// right half of the child through the item separating them.
+	var rsep *Item
+	var rest *node
+	var resth int
+	if i < len(n.items) {
+		rsep = n.items[i]
+		rest = c.newNode()
+		rest.items = append(rest.items, n.items[i+1:]...)
+		rest.children = append(rest.children, n.children[i+1:]...)
+		rest, resth = c.collapse(rest, h)
+	}
+	// Likewise, n keeps everything left of the child.
+	var lsep *Item
+	var nh int
+	if i > 0 {
+		lsep = n.items[i-1]
+		n.items.truncate(i - 1)
+		n.children.truncate(i)
+		n, nh = c.collapse(n, h)
+	} else {
+		c.freeNode(n)
+		n = nil
+	}
+	l, lh, r, rh = c.splitAt(child, h-1, key, maxItems)
+	if n != nil {
+		l, lh = c.join(n, nh, lsep, l, lh, maxItems)
+	}
+	if rest != nil {
+		r, rh = c.join(r, rh, rsep, rest, resth, maxItems)
+	}
+	return
+}
+
+// DeleteRange removes every item in the range [greaterOrEqual, lessThan) from
+// the tree, returning the number of items removed.  A nil bound leaves that
+// side of the range open.
+//
+// Rather than deleting items one by one, the tree is split at both bounds and
+// the outer parts joined back together, so this takes O(log n + removed).
+func (t *BTree) DeleteRange(greaterOrEqual, lessThan *Item) int {
+	return t.deleteRange(greaterOrEqual, lessThan, true)
+}
+
+// deleteRange implements DeleteRange, releasing the removed items to the
+// tree's item pool only if release is set.
+func (t *BTree) deleteRange(greaterOrEqual, lessThan *Item, release bool) int {
+	if t.root == nil {
+		return 0
+	}
+	greaterOrEqual, lessThan, ok := t.bounds(greaterOrEqual, lessThan)
+	if !ok {
+		return 0
+	}
+	if greaterOrEqual != nil && lessThan != nil && !greaterOrEqual.Less(lessThan) {
+		return 0
+	}
+	c, maxItems := t.cow, t.maxItems()
+	var l, mid, r *node
+	var lh, mh, rh int
+	mid, mh = t.root, t.root.height()
+	if greaterOrEqual != nil {
+		l, lh, mid, mh = c.splitAt(mid, mh, greaterOrEqual, maxItems)
+	}
+	if lessThan != nil && mid != nil {
+		mid, mh, r, rh = c.splitAt(mid, mh, lessThan, maxItems)
+	}
+	removed := 0
+	if mid != nil {
+		removed = mid.size()
+		if release {
+			t.releaseSubtree(mid)
+		}
+		mid.reset(c)
+	}
+	t.root, _ = c.concat(l, lh, r, rh, t.minItems(), maxItems)
+	t.length -= removed
+	if removed > 0 {
+		t.resetBounds()
+	}
+	return removed
+}
+
+// DeleteMinN removes the n smallest items from the tree and returns them in
+// ascending order, or all items if the tree holds fewer than n.  The items are
+// collected in a single ordered pass and then cut off with DeleteRange, which
+// is much cheaper than n calls to DeleteMin.
+func (t *BTree) DeleteMinN(n int) []*Item {
+	out, next := t.firstN(n, t.Ascend)
+	if len(out) > 0 {
+		t.deleteFirstN(out, func() { t.deleteRange(nil, next, false) }, t.DeleteMin)
+	}
+	return out
+}
+
+// DeleteMaxN removes the n largest items from the tree and returns them in
+// descending order, or all items if the tree holds fewer than n.  See
+// DeleteMinN.
+func (t *BTree) DeleteMaxN(n int) []*Item {
+	out, _ := t.firstN(n, t.Descend)
+	if len(out) > 0 {
+		t.deleteFirstN(out, func() { t.deleteRange(out[len(out)-1], nil, false) }, t.DeleteMax)
+	}
+	return out
+}
+
+// firstN returns the first n items visited by iterate along with the one
+// after them, which is nil if there is none.
+func (t *BTree) firstN(n int, iterate func(ItemIterator)) (out []*Item, next *Item) {
+	if n > t.length {
+		n = t.length
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+	out = make([]*Item, 0, n)
+	iterate(func(i *Item) bool {
+		if len(out) == n {
+			next = i
+			return false
+		}
+		out = append(out, i)
+		return true
+	})
+	return
+}
+
+// deleteFirstN removes the items in out, the first of the tree in some order,
+// with deleteRange.  In trees created by NewMulti, items equal to the last of
+// them may be left in the tree, which a range cannot tell apart, so those are
+// removed with deleteOne one at a time instead.
+func (t *BTree) deleteFirstN(out []*Item, deleteRange func(), deleteOne func() *Item) {
+	if !t.multi {
+		deleteRange()
+		return
+	}
+	for range out {
+		deleteOne()
+	}
+}
+
+// Split returns two new trees, holding the items of t less than key and the
+// items greater than or equal to key respectively, in the tree's order.
+//
+// t itself is left unchanged.  The new trees share its nodes copy-on-write, as
+// with Clone, so only the O(log n) nodes along the split path are copied.
+// Counting the items that end up in each tree still visits every node of the
+// right one.
+func (t *BTree) Split(key *Item) (left, right *BTree) {
+	left = t.Clone()
+	right = left.Clone()
+	if left.root == nil {
+		return
+	}
+	if t.reverse {
+		// The items before key in reverse order are those after it in key
+		// order, which start at the item following it.
+		if key = t.root.successor(key); key == nil {
+			left.root, left.length = nil, 0
+			left.resetBounds()
+			return
+		}
+		defer func() { left, right = right, left }()
+	}
+	var l, r *node
+	l, _, r, _ = left.cow.splitAt(left.root, left.root.height(), key, t.maxItems())
+	left.root, right.root = l, r
+	right.length = 0
+	if r != nil {
+		right.length = r.size()
+	}
+	left.length -= right.length
+	left.resetBounds()
+	right.resetBounds()
+	return
+}
+
+// Merge adds every item of other to t.  All items of other must sort either
+// before or after all items of t; otherwise ErrOverlap is returned and t is
+// left unchanged.
+//
+// other is not modified.  When both trees have the same degree, t adopts the
+// nodes of other copy-on-write and joins them to its own in O(log n);
+// otherwise the items of other are inserted one at a time.
+func (t *BTree) Merge(other *BTree) error {
+	if other.Len() == 0 {
+		return nil
+	}
+	if t.Len() > 0 && !max(t.root).Less(min(other.root)) && !max(other.root).Less(min(t.root)) {
+		return ErrOverlap
+	}
+	if other.degree != t.degree {
+		other.walk(func(i *Item) bool {
+			t.ReplaceOrInsert(i)
+			return true
+		})
+		return nil
+	}
+	o := other.Clone()
+	if t.Len() == 0 {
+		t.root, t.length = o.root, o.length
+		t.resetBounds()
+		return nil
+	}
+	l, lh, r, rh := t.root, t.root.height(), o.root, o.root.height()
+	if r.items[0].Less(t.root.items[0]) {
+		l, lh, r, rh = r, rh, l, lh
+	}
+	t.root, _ = t.cow.concat(l, lh, r, rh, t.minItems(), t.maxItems())
+	t.length += o.length
+	t.resetBounds()
+	return nil
+}
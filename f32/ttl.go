@@ -0,0 +1,99 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package f32
+
+import (
+	"container/heap"
+	"time"
+)
+
+// ExpiringTree is a BTree whose items may be given a time to live, after which
+// ExpireBefore removes them.  Deadlines are kept in a min-heap beside the
+// tree, so a sweep only looks at the items that actually expire.
+//
+// Items added or replaced through the embedded BTree's own methods have no
+// deadline.  An item deleted or replaced before its deadline is simply
+// skipped when the deadline comes up, and its entry in the heap dropped then.
+type ExpiringTree struct {
+	*BTree
+	expiries  expiryHeap
+	deadlines map[*Item]time.Time
+}
+
+// NewExpiring creates a new, empty ExpiringTree with the given degree.
+func NewExpiring(degree int) *ExpiringTree {
+	return &ExpiringTree{BTree: New(degree), deadlines: make(map[*Item]time.Time)}
+}
+
+// InsertWithTTL adds the given item to the tree as ReplaceOrInsert does, to be
+// removed by the first call to ExpireBefore with a time more than d from now.
+// Adding an item that is already in the tree again resets its deadline.
+func (t *ExpiringTree) InsertWithTTL(item *Item, d time.Duration) *Item {
+	out := t.ReplaceOrInsert(item)
+	if out != nil {
+		delete(t.deadlines, out)
+	}
+	deadline := time.Now().Add(d)
+	t.deadlines[item] = deadline
+	heap.Push(&t.expiries, expiry{deadline: deadline, item: item})
+	return out
+}
+
+// ExpireBefore removes every item whose deadline is before now from the tree,
+// returning the number of items removed.  It takes O(k log n) for k expired
+// deadlines.
+func (t *ExpiringTree) ExpireBefore(now time.Time) int {
+	removed := 0
+	for len(t.expiries) > 0 && t.expiries[0].deadline.Before(now) {
+		e := heap.Pop(&t.expiries).(expiry)
+		if deadline, ok := t.deadlines[e.item]; !ok || !deadline.Equal(e.deadline) {
+			continue // the item got a new deadline since
+		}
+		delete(t.deadlines, e.item)
+		if t.Get(e.item) == e.item {
+			t.Delete(e.item)
+			if t.itemPool != nil {
+				t.itemPool.Release(e.item)
+			}
+			removed++
+		}
+	}
+	return removed
+}
+
+// expiry is the deadline of an item, as stored in an expiryHeap.
+type expiry struct {
+	deadline time.Time
+	item     *Item
+}
+
+// expiryHeap is a min-heap of expiries by deadline, for container/heap.
+type expiryHeap []expiry
+
+func (h expiryHeap) Len() int            { return len(h) }
+func (h expiryHeap) Less(i, j int) bool  { return h[i].deadline.Before(h[j].deadline) }
+func (h expiryHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *expiryHeap) Push(x interface{}) { *h = append(*h, x.(expiry)) }
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = expiry{}
+	*h = old[:len(old)-1]
+	return e
+}
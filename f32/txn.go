@@ -0,0 +1,86 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package f32
+
+import "errors"
+
+var (
+	// ErrTxnDone is returned by Txn.Commit after the transaction has been
+	// committed or rolled back.
+	ErrTxnDone = errors.New("btree: transaction already committed or rolled back")
+	// ErrTxnConflict is returned by Txn.Commit when the parent tree was
+	// modified after the transaction began.
+	ErrTxnConflict = errors.New("btree: tree modified during transaction")
+)
+
+// Txn is a set of changes to a tree that is applied all at once by Commit or
+// discarded by Rollback, as returned by BTree.Begin.  The embedded BTree is a
+// private clone of the parent tree: changes made through it, and reads of
+// them, go to the clone only until Commit.
+//
+// Like BTree, a Txn is not safe for concurrent use, nor is committing it
+// concurrently with other writes to the parent.
+type Txn struct {
+	*BTree
+	parent *BTree
+	base   *node
+	length int
+	done   bool
+}
+
+// Begin starts a transaction on the tree.  This takes O(1), as the clone the
+// transaction works on shares its nodes with t copy-on-write.
+func (t *BTree) Begin() *Txn {
+	clone := t.Clone()
+	return &Txn{BTree: clone, parent: t, base: t.root, length: t.length}
+}
+
+// Commit makes the changes of the transaction visible in the parent tree in a
+// single step, and leaves the embedded BTree empty and detached from the
+// parent, so that later writes to it are discarded.  If the parent was written
+// to since Begin, ErrTxnConflict is returned instead and the parent is left
+// unchanged, so that no changes are lost; the transaction then stays open and
+// may be rolled back.
+func (txn *Txn) Commit() error {
+	if txn.done {
+		return ErrTxnDone
+	}
+	if txn.parent.root != txn.base || txn.parent.length != txn.length {
+		return ErrTxnConflict
+	}
+	txn.done = true
+	*txn.parent = *txn.BTree
+	// The transaction would otherwise keep writing to the nodes it has just
+	// handed over, so it is left with a clone, emptied like after Rollback.
+	txn.BTree = txn.parent.Clone()
+	txn.root, txn.length = nil, 0
+	txn.resetBounds()
+	return nil
+}
+
+// Rollback discards the changes of the transaction, returning the nodes it
+// copied to the free list.  It does nothing if the transaction has already
+// been committed or rolled back.
+func (txn *Txn) Rollback() {
+	if txn.done {
+		return
+	}
+	txn.done = true
+	txn.BTree.Clear(true)
+}
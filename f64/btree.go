@@ -47,8 +47,8 @@
 // trees, (http://github.com/petar/gollrb), an excellent and probably the most
 // widely used ordered tree implementation in the Go ecosystem currently.
 // Its functions, therefore, exactly mirror those of
-// llrb.LLRB where possible.  Unlike gollrb, though, we currently don't
-// support storing multiple equivalent values.
+// llrb.LLRB where possible.  Unlike gollrb, though, multiple equivalent values
+// can only be stored in trees created by NewMulti.
 package f64
 
 import (
@@ -59,7 +59,8 @@ import (
 	"sync"
 )
 
-// Item represents a single object in the tree.
+// Item represents a single object in the tree.  Only Key takes part in the
+// ordering; Payload holds the value associated with it.
 type Item struct {
 	Key     float64
 	SubTree *BTree
@@ -89,8 +90,25 @@ var (
 // FreeList.
 // Two Btrees using the same freelist are safe for concurrent write access.
 type FreeList struct {
-	mu       sync.Mutex
-	freelist []*node
+	mu        sync.Mutex
+	freelist  []*node
+	pool      *sync.Pool
+	arena     *arena
+	hits      uint64
+	misses    uint64
+	discards  uint64
+	highWater int
+}
+
+// FreeListStats describes the activity of a FreeList, as returned by
+// FreeList.Stats.
+type FreeListStats struct {
+	Hits      uint64 // nodes handed out from the list
+	Misses    uint64 // nodes allocated because the list was empty
+	Discards  uint64 // freed nodes dropped because the list was full
+	Size      int    // nodes currently in the list
+	MaxSize   int    // maximum number of nodes the list holds
+	HighWater int    // largest number of nodes the list has held at once
 }
 
 // NewFreeList creates a new free list.
@@ -99,13 +117,28 @@ func NewFreeList(size int) *FreeList {
 	return &FreeList{freelist: make([]*node, 0, size)}
 }
 
+// poolFreeList is the free list shared by all trees created by NewWithPool.
+var poolFreeList = &FreeList{
+	pool: &sync.Pool{New: func() interface{} { return new(node) }},
+}
+
 func (f *FreeList) newNode() (n *node) {
+	if f.pool != nil {
+		return f.pool.Get().(*node)
+	}
 	f.mu.Lock()
 	index := len(f.freelist) - 1
 	if index < 0 {
+		f.misses++
+		if f.arena != nil {
+			n = f.arena.newNode()
+			f.mu.Unlock()
+			return
+		}
 		f.mu.Unlock()
 		return new(node)
 	}
+	f.hits++
 	n = f.freelist[index]
 	f.freelist[index] = nil
 	f.freelist = f.freelist[:index]
@@ -116,20 +149,67 @@ func (f *FreeList) newNode() (n *node) {
 // freeNode adds the given node to the list, returning true if it was added
 // and false if it was discarded.
 func (f *FreeList) freeNode(n *node) (out bool) {
+	if f.pool != nil {
+		f.pool.Put(n)
+		return true
+	}
 	f.mu.Lock()
 	if len(f.freelist) < cap(f.freelist) {
 		f.freelist = append(f.freelist, n)
+		if len(f.freelist) > f.highWater {
+			f.highWater = len(f.freelist)
+		}
 		out = true
+	} else {
+		f.discards++
 	}
 	f.mu.Unlock()
 	return
 }
 
+// Stats returns a snapshot of the free list's counters.
+func (f *FreeList) Stats() FreeListStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return FreeListStats{
+		Hits:      f.hits,
+		Misses:    f.misses,
+		Discards:  f.discards,
+		Size:      len(f.freelist),
+		MaxSize:   cap(f.freelist),
+		HighWater: f.highWater,
+	}
+}
+
+// SetMaxSize changes the maximum size of the free list.  If the list holds
+// more than size nodes, the extra ones are dropped for the GC to collect.
+func (f *FreeList) SetMaxSize(size int) {
+	f.mu.Lock()
+	freelist := make([]*node, 0, size)
+	if len(f.freelist) > size {
+		freelist = append(freelist, f.freelist[:size]...)
+	} else {
+		freelist = append(freelist, f.freelist...)
+	}
+	f.freelist = freelist
+	f.mu.Unlock()
+}
+
 // ItemIterator allows callers of Ascend* to iterate in-order over portions of
 // the tree.  When this function returns false, iteration will stop and the
 // associated Ascend* function will immediately return.
 type ItemIterator func(i *Item) bool
 
+// KeyIterator allows callers of AscendKeys and DescendKeys to iterate in-order
+// over the keys of the tree.  When this function returns false, iteration will
+// stop and the associated function will immediately return.
+type KeyIterator func(key float64) bool
+
+// MergeFunc combines an item already in the tree with a new, equal item
+// passed to Upsert, returning the item to store in its place.  The returned
+// item must be equal to both of them.
+type MergeFunc func(existing, new *Item) *Item
+
 // New creates a new B-Tree with the given degree.
 //
 // New(2), for example, will create a 2-3-4 tree (each node contains 1-3 items
@@ -138,6 +218,27 @@ func New(degree int) *BTree {
 	return NewWithFreeList(degree, NewFreeList(DefaultFreeListSize))
 }
 
+// NewWithPool creates a new B-Tree with the given degree whose nodes are
+// recycled through a package-level sync.Pool shared by all such trees, rather
+// than through a free list of its own.  This lets short-lived trees created
+// across many goroutines reuse each other's nodes, and the pool is drained by
+// the GC when nodes sit unused.  The pool keeps no statistics.
+func NewWithPool(degree int) *BTree {
+	return NewWithFreeList(degree, poolFreeList)
+}
+
+// NewReverse creates a new B-Tree with the given degree that orders its items
+// from the greatest key to the least: Min returns the item with the greatest
+// key, Ascend visits items in descending key order, and likewise for every
+// other method that depends on the order, including the bounds of ranges.
+// Items themselves still implement Less in ascending order.  For trees that
+// are also multi, equal items come in reverse insertion order.
+func NewReverse(degree int) *BTree {
+	t := New(degree)
+	t.reverse = true
+	return t
+}
+
 // NewWithFreeList creates a new B-Tree that uses the given node free list.
 func NewWithFreeList(degree int, f *FreeList) *BTree {
 	if degree <= 1 {
@@ -204,6 +305,22 @@ func (s items) find(item *Item) (index int, found bool) {
 	return i, false
 }
 
+// upperBound returns the index of the first item in the list that is greater
+// than the given item, or len(s) if there is none.
+func (s items) upperBound(item *Item) int {
+	return sort.Search(len(s), func(i int) bool {
+		return item.Less(s[i])
+	})
+}
+
+// lowerBound returns the index of the first item in the list that is not less
+// than the given item, or len(s) if there is none.
+func (s items) lowerBound(item *Item) int {
+	return sort.Search(len(s), func(i int) bool {
+		return !s[i].Less(item)
+	})
+}
+
 // children stores child nodes in a node.
 type children []*node
 
@@ -255,10 +372,19 @@ type node struct {
 	items    items
 	children children
 	cow      *copyOnWriteContext
+	// digest caches the digest of the subtree for RangeDigest, and is valid
+	// while digested is 1.  Both are accessed atomically, since readers of
+	// the tree fill them in.
+	digest   uint64
+	digested uint32
 }
 
 func (n *node) mutableFor(cow *copyOnWriteContext) *node {
+	cow.writes++
 	if n.cow == cow {
+		// The node is about to change.  No one may read it meanwhile, so
+		// the cached digest can be dropped without synchronization.
+		n.digested = 0
 		return n
 	}
 	out := cow.newNode()
@@ -314,12 +440,13 @@ func (n *node) maybeSplitChild(i, maxItems int) bool {
 
 // insert inserts an item into the subtree rooted at this node, making sure
 // no nodes in the subtree exceed maxItems items.  Should an equivalent item be
-// be found/replaced by insert, it will be returned.
-func (n *node) insert(item *Item, maxItems int) *Item {
+// be found/replaced by insert, it will be returned.  If merge is not nil, the
+// found item is replaced by merge(found, item) instead of item.
+func (n *node) insert(item *Item, maxItems int, merge MergeFunc) *Item {
 	i, found := n.items.find(item)
 	if found {
 		out := n.items[i]
-		n.items[i] = item
+		n.items[i] = merged(out, item, merge)
 		return out
 	}
 	if len(n.children) == 0 {
@@ -335,11 +462,23 @@ func (n *node) insert(item *Item, maxItems int) *Item {
 			i++ // we want second split node
 		default:
 			out := n.items[i]
-			n.items[i] = item
+			n.items[i] = merged(out, item, merge)
 			return out
 		}
 	}
-	return n.mutableChild(i).insert(item, maxItems)
+	return n.mutableChild(i).insert(item, maxItems, merge)
+}
+
+// merged returns the item that should replace existing when item is inserted.
+func merged(existing, item *Item, merge MergeFunc) *Item {
+	if merge == nil {
+		return item
+	}
+	out := merge(existing, item)
+	if out == nil {
+		panic("nil item returned by MergeFunc")
+	}
+	return out
 }
 
 // get finds the given key in the subtree and returns it.
@@ -381,6 +520,129 @@ func max(n *node) *Item {
 	return n.items[len(n.items)-1]
 }
 
+// floor returns the greatest item in the subtree that is less than or equal
+// to key, or nil if there is none.
+func (n *node) floor(key *Item) *Item {
+	i := n.items.upperBound(key)
+	if len(n.children) > 0 {
+		if out := n.children[i].floor(key); out != nil {
+			return out
+		}
+	}
+	if i > 0 {
+		return n.items[i-1]
+	}
+	return nil
+}
+
+// ceiling returns the least item in the subtree that is greater than or equal
+// to key, or nil if there is none.
+func (n *node) ceiling(key *Item) *Item {
+	i := n.items.lowerBound(key)
+	if len(n.children) > 0 {
+		if out := n.children[i].ceiling(key); out != nil {
+			return out
+		}
+	}
+	if i < len(n.items) {
+		return n.items[i]
+	}
+	return nil
+}
+
+// successor returns the least item in the subtree that is greater than key,
+// or nil if there is none.
+func (n *node) successor(key *Item) *Item {
+	i := n.items.upperBound(key)
+	if len(n.children) > 0 {
+		if out := n.children[i].successor(key); out != nil {
+			return out
+		}
+	}
+	if i < len(n.items) {
+		return n.items[i]
+	}
+	return nil
+}
+
+// predecessor returns the greatest item in the subtree that is less than key,
+// or nil if there is none.
+func (n *node) predecessor(key *Item) *Item {
+	i := n.items.lowerBound(key)
+	if len(n.children) > 0 {
+		if out := n.children[i].predecessor(key); out != nil {
+			return out
+		}
+	}
+	if i > 0 {
+		return n.items[i-1]
+	}
+	return nil
+}
+
+// firstMatch returns the least item in the subtree for which pred is true,
+// or nil if there is none, given that pred is monotone over the subtree.
+func (n *node) firstMatch(pred func(*Item) bool) *Item {
+	i := sort.Search(len(n.items), func(i int) bool {
+		return pred(n.items[i])
+	})
+	if len(n.children) > 0 {
+		if out := n.children[i].firstMatch(pred); out != nil {
+			return out
+		}
+	}
+	if i < len(n.items) {
+		return n.items[i]
+	}
+	return nil
+}
+
+// lastMatch returns the greatest item in the subtree for which pred is true,
+// or nil if there is none, given that pred is true up to some point of the
+// subtree and false from there on.
+func (n *node) lastMatch(pred func(*Item) bool) *Item {
+	i := sort.Search(len(n.items), func(i int) bool {
+		return !pred(n.items[i])
+	})
+	if len(n.children) > 0 {
+		if out := n.children[i].lastMatch(pred); out != nil {
+			return out
+		}
+	}
+	if i > 0 {
+		return n.items[i-1]
+	}
+	return nil
+}
+
+// count returns the number of items of the subtree in the range
+// [greaterOrEqual, lessThan), where a nil bound leaves that side open.
+// Subtrees entirely within the range are counted by their size.
+func (n *node) count(greaterOrEqual, lessThan *Item) int {
+	lo, hi := 0, len(n.items)
+	if greaterOrEqual != nil {
+		lo = n.items.lowerBound(greaterOrEqual)
+	}
+	if lessThan != nil {
+		hi = n.items.lowerBound(lessThan)
+	}
+	if hi < lo {
+		return 0
+	}
+	out := hi - lo
+	if len(n.children) == 0 {
+		return out
+	}
+	if lo == hi {
+		return out + n.children[lo].count(greaterOrEqual, lessThan)
+	}
+	out += n.children[lo].count(greaterOrEqual, nil)
+	for _, c := range n.children[lo+1 : hi] {
+		out += c.size()
+	}
+	return out + n.children[hi].count(nil, lessThan)
+}
+
 // toRemove details what item to remove in a node.remove call.
 type toRemove int
 
@@ -388,6 +650,8 @@ const (
 	removeItem toRemove = iota // removes the given item
 	removeMin                  // removes smallest item in the subtree
 	removeMax                  // removes largest item in the subtree
+	removeFirst                // removes the first of the items equal to the given one
+	removeExact                // removes the given item itself among equal ones
 )
 
 // remove removes an item from the subtree rooted at this node.
@@ -413,6 +677,35 @@ func (n *node) remove(item *Item, minItems int, typ toRemove) *Item {
 			}
 			return nil
 		}
+	case removeFirst:
+		i = n.items.lowerBound(item)
+		found = i < len(n.items) && !item.Less(n.items[i])
+		if len(n.children) == 0 {
+			if found {
+				return n.items.removeAt(i)
+			}
+			return nil
+		}
+		if found && !max(n.children[i]).Less(item) {
+			// An earlier equal item lives in the child.
+			found = false
+		}
+	case removeExact:
+		i = -1
+		lo, hi := n.items.lowerBound(item), n.items.upperBound(item)
+		for j := lo; j <= hi && i < 0; j++ {
+			if len(n.children) > 0 && n.children[j].holds(item) {
+				i = j
+			} else if j < hi && n.items[j] == item {
+				i, found = j, true
+			}
+		}
+		if i < 0 {
+			return nil
+		}
+		if len(n.children) == 0 {
+			return n.items.removeAt(i)
+		}
 	default:
 		panic("invalid type")
 	}
@@ -502,6 +795,23 @@ const (
 	ascend  = direction(+1)
 )
 
+// order returns the direction in which the tree's nodes must be walked to
+// visit its items in direction dir of the tree's own order.
+func (t *BTree) order(dir direction) direction {
+	if t.reverse {
+		return -dir
+	}
+	return dir
+}
+
+// walk calls iter for every item in ascending key order, whatever the order
+// of the tree, until iter returns false.
+func (t *BTree) walk(iter ItemIterator) {
+	if t.root != nil {
+		t.root.iterate(ascend, nil, nil, false, false, iter)
+	}
+}
+
 // iterate provides a simple method for iterating over elements in the tree.
 //
 // When ascending, the 'start' should be less than 'stop' and when descending,
@@ -515,7 +825,7 @@ func (n *node) iterate(dir direction, start, stop *Item, includeStart bool, hit
 	switch dir {
 	case ascend:
 		if start != nil {
-			index, _ = n.items.find(start)
+			index = n.items.lowerBound(start)
 		}
 		for i := index; i < len(n.items); i++ {
 			if len(n.children) > 0 {
@@ -551,7 +861,7 @@ func (n *node) iterate(dir direction, start, stop *Item, includeStart bool, hit
 		}
 		for i := index; i >= 0; i-- {
 			if start != nil && !n.items[i].Less(start) {
-				if !includeStart || hit || start.Less(n.items[i]) {
+				if !includeStart || start.Less(n.items[i]) {
 					continue
 				}
 			}
@@ -597,6 +907,19 @@ type BTree struct {
 	length int
 	root   *node
 	cow    *copyOnWriteContext
+	multi  bool
+	// reverse flips the order seen through the public methods of trees
+	// created by NewReverse.  Nodes are always kept in ascending key order.
+	reverse bool
+	// first and last cache the least and greatest items in key order, nil
+	// when the tree is empty, so that Min and Max take O(1).
+	first, last *Item
+	// itemPool receives the items the tree drops without handing them back,
+	// if set.  See SetItemPool.
+	itemPool *ItemPool
+	// codec encodes items for MarshalBinary and decodes them for
+	// UnmarshalBinary, if set; DefaultItemCodec is used otherwise.
+	codec ItemCodec
 }
 
 // copyOnWriteContext pointers determine node ownership... a tree with a write
@@ -615,6 +938,9 @@ type BTree struct {
 // copy.
 type copyOnWriteContext struct {
 	freelist *FreeList
+	// writes counts the nodes created or made mutable in this context, so
+	// that caches of lookups can tell when the tree may have changed.
+	writes uint64
 }
 
 // Clone clones the btree, lazily.  Clone should not be called concurrently,
@@ -641,6 +967,30 @@ func (t *BTree) Clone() (t2 *BTree) {
 	return &out
 }
 
+// CloneDeep clones the btree eagerly, copying every node up front.  Unlike
+// with Clone, the two trees share no nodes, so writes to either never pay for
+// copy-on-write; t in particular keeps writing to its nodes in place.  This
+// costs O(n) time and memory for the copy.
+func (t *BTree) CloneDeep() *BTree {
+	out := *t
+	out.cow = &copyOnWriteContext{freelist: t.cow.freelist}
+	if t.root != nil {
+		out.root = t.root.deepCopy(out.cow)
+	}
+	return &out
+}
+
+// deepCopy returns a copy of the subtree rooted at n, made of new nodes owned
+// by cow.
+func (n *node) deepCopy(cow *copyOnWriteContext) *node {
+	out := cow.newNode()
+	out.items = append(out.items, n.items...)
+	for _, c := range n.children {
+		out.children = append(out.children, c.deepCopy(cow))
+	}
+	return out
+}
+
 // maxItems returns the max number of items to allow per node.
 func (t *BTree) maxItems() int {
 	return t.degree*2 - 1
@@ -655,6 +1005,7 @@ func (t *BTree) minItems() int {
 func (c *copyOnWriteContext) newNode() (n *node) {
 	n = c.freelist.newNode()
 	n.cow = c
+	c.writes++
 	return
 }
 
@@ -675,6 +1026,7 @@ func (c *copyOnWriteContext) freeNode(n *node) freeType {
 		n.items.truncate(0)
 		n.children.truncate(0)
 		n.cow = nil
+		n.digested = 0
 		if c.freelist.freeNode(n) {
 			return ftStored
 		} else {
@@ -687,10 +1039,26 @@ func (c *copyOnWriteContext) freeNode(n *node) freeType {
 
 // ReplaceOrInsert adds the given item to the tree.  If an item in the tree
 // already equals the given one, it is removed from the tree and returned.
-// Otherwise, nil is returned.
+// Otherwise, nil is returned.  Trees created by NewMulti never replace items:
+// the item is added after any equal ones and nil is returned.
 //
 // nil cannot be added to the tree (will panic).
 func (t *BTree) ReplaceOrInsert(item *Item) *Item {
+	return t.insert(item, nil)
+}
+
+// Upsert adds the given item to the tree.  If an item in the tree already
+// equals the given one, it is replaced by merge(existing, item) and the
+// existing item is returned; the lookup and the update happen in a single
+// descent.  Otherwise item is inserted as is and nil is returned.  Trees
+// created by NewMulti always insert item as is.
+//
+// nil cannot be added to the tree (will panic).
+func (t *BTree) Upsert(item *Item, merge MergeFunc) *Item {
+	return t.insert(item, merge)
+}
+
+func (t *BTree) insert(item *Item, merge MergeFunc) *Item {
 	if item == nil {
 		panic("nil item being added to BTree")
 	}
@@ -698,6 +1066,9 @@ func (t *BTree) ReplaceOrInsert(item *Item) *Item {
 		t.root = t.cow.newNode()
 		t.root.items = append(t.root.items, item)
 		t.length++
+		t.first, t.last = item, item
+		return nil
+	} else if t.appendRight(item) {
 		return nil
 	} else {
 		t.root = t.root.mutableFor(t.cow)
@@ -709,28 +1080,85 @@ func (t *BTree) ReplaceOrInsert(item *Item) *Item {
 			t.root.children = append(t.root.children, oldroot, second)
 		}
 	}
-	out := t.root.insert(item, t.maxItems())
+	var out *Item
+	if t.multi {
+		t.root.insertMulti(item, t.maxItems())
+	} else {
+		out = t.root.insert(item, t.maxItems(), merge)
+	}
 	if out == nil {
 		t.length++
 	}
+	// An item equal to or past either end may have become the new end.
+	// Looking it up rather than taking item covers merges and equal items.
+	if t.first == nil || !t.first.Less(item) {
+		t.first = min(t.root)
+	}
+	if t.last == nil || !item.Less(t.last) {
+		t.last = max(t.root)
+	}
 	return out
 }
 
+// appendRight adds item to the end of the rightmost leaf if it sorts after
+// every item in the tree, as with timestamps and other increasing keys, and
+// reports whether it did.  The leaf is reached by following the right spine
+// without comparing any keys.  This only works if the spine is owned by the
+// tree, which a previous insert leaves it, and the leaf has room; otherwise
+// the item is left to the regular insert, which splits the full nodes and
+// copies the shared ones on its way down.
+func (t *BTree) appendRight(item *Item) bool {
+	if t.last == nil || t.multi && item.Less(t.last) || !t.multi && !t.last.Less(item) {
+		return false
+	}
+	n := t.root
+	for n.cow == t.cow {
+		if len(n.children) == 0 {
+			if len(n.items) >= t.maxItems() {
+				return false
+			}
+			n.items = append(n.items, item)
+			t.cow.writes++
+			for n = t.root; ; n = n.children[len(n.children)-1] {
+				n.digested = 0
+				if len(n.children) == 0 {
+					break
+				}
+			}
+			t.length++
+			t.last = item
+			return true
+		}
+		n = n.children[len(n.children)-1]
+	}
+	return false
+}
+
 // Delete removes an item equal to the passed in item from the tree, returning
-// it.  If no such item exists, returns nil.
+// it.  If no such item exists, returns nil.  For trees created by NewMulti,
+// this is the same as DeleteOne.
 func (t *BTree) Delete(item *Item) *Item {
+	if t.multi {
+		return t.deleteItem(item, removeFirst)
+	}
 	return t.deleteItem(item, removeItem)
 }
 
 // DeleteMin removes the smallest item in the tree and returns it.
 // If no such item exists, returns nil.
 func (t *BTree) DeleteMin() *Item {
+	if t.reverse {
+		return t.deleteItem(nil, removeMax)
+	}
 	return t.deleteItem(nil, removeMin)
 }
 
 // DeleteMax removes the largest item in the tree and returns it.
 // If no such item exists, returns nil.
 func (t *BTree) DeleteMax() *Item {
+	if t.reverse {
+		return t.deleteItem(nil, removeMin)
+	}
 	return t.deleteItem(nil, removeMax)
 }
 
@@ -748,16 +1176,28 @@ func (t *BTree) deleteItem(item *Item, typ toRemove) *Item {
 	if out != nil {
 		t.length--
 	}
+	if out == t.first {
+		t.first = min(t.root)
+	}
+	if out == t.last {
+		t.last = max(t.root)
+	}
 	return out
 }
 
+// resetBounds recomputes the cached first and last items after an operation
+// that may have changed them.
+func (t *BTree) resetBounds() {
+	t.first, t.last = min(t.root), max(t.root)
+}
+
 // AscendRange calls the iterator for every value in the tree within the range
 // [greaterOrEqual, lessThan), until iterator returns false.
 func (t *BTree) AscendRange(greaterOrEqual, lessThan *Item, iterator ItemIterator) {
 	if t.root == nil {
 		return
 	}
-	t.root.iterate(ascend, greaterOrEqual, lessThan, true, false, iterator)
+	t.root.iterate(t.order(ascend), greaterOrEqual, lessThan, true, false, iterator)
 }
 
 // AscendLessThan calls the iterator for every value in the tree within the range
@@ -766,7 +1206,7 @@ func (t *BTree) AscendLessThan(pivot *Item, iterator ItemIterator) {
 	if t.root == nil {
 		return
 	}
-	t.root.iterate(ascend, nil, pivot, false, false, iterator)
+	t.root.iterate(t.order(ascend), nil, pivot, false, false, iterator)
 }
 
 // AscendGreaterOrEqual calls the iterator for every value in the tree within
@@ -775,7 +1215,7 @@ func (t *BTree) AscendGreaterOrEqual(pivot *Item, iterator ItemIterator) {
 	if t.root == nil {
 		return
 	}
-	t.root.iterate(ascend, pivot, nil, true, false, iterator)
+	t.root.iterate(t.order(ascend), pivot, nil, true, false, iterator)
 }
 
 // Ascend calls the iterator for every value in the tree within the range
@@ -784,7 +1224,7 @@ func (t *BTree) Ascend(iterator ItemIterator) {
 	if t.root == nil {
 		return
 	}
-	t.root.iterate(ascend, nil, nil, false, false, iterator)
+	t.root.iterate(t.order(ascend), nil, nil, false, false, iterator)
 }
 
 // DescendRange calls the iterator for every value in the tree within the range
@@ -793,7 +1233,7 @@ func (t *BTree) DescendRange(lessOrEqual, greaterThan *Item, iterator ItemIterat
 	if t.root == nil {
 		return
 	}
-	t.root.iterate(descend, lessOrEqual, greaterThan, true, false, iterator)
+	t.root.iterate(t.order(descend), lessOrEqual, greaterThan, true, false, iterator)
 }
 
 // DescendLessOrEqual calls the iterator for every value in the tree within the range
@@ -802,7 +1242,7 @@ func (t *BTree) DescendLessOrEqual(pivot *Item, iterator ItemIterator) {
 	if t.root == nil {
 		return
 	}
-	t.root.iterate(descend, pivot, nil, true, false, iterator)
+	t.root.iterate(t.order(descend), pivot, nil, true, false, iterator)
 }
 
 // DescendGreaterThan calls the iterator for every value in the tree within
@@ -811,7 +1251,7 @@ func (t *BTree) DescendGreaterThan(pivot *Item, iterator ItemIterator) {
 	if t.root == nil {
 		return
 	}
-	t.root.iterate(descend, nil, pivot, false, false, iterator)
+	t.root.iterate(t.order(descend), nil, pivot, false, false, iterator)
 }
 
 // Descend calls the iterator for every value in the tree within the range
@@ -820,11 +1260,196 @@ func (t *BTree) Descend(iterator ItemIterator) {
 	if t.root == nil {
 		return
 	}
-	t.root.iterate(descend, nil, nil, false, false, iterator)
+	t.root.iterate(t.order(descend), nil, nil, false, false, iterator)
+}
+
+// AscendBetween calls the iterator for every value in the tree between lo and
+// hi in ascending order, until iterator returns false.  loInclusive and
+// hiInclusive choose whether items equal to each bound are included, as with
+// SQL's BETWEEN when both are true.  A nil bound leaves that side open.
+func (t *BTree) AscendBetween(lo, hi *Item, loInclusive, hiInclusive bool, iterator ItemIterator) {
+	if t.root == nil {
+		return
+	}
+	t.root.iterate(t.order(ascend), lo, nil, true, false, between(t.less, lo, hi, loInclusive, hiInclusive, iterator))
+}
+
+// DescendBetween calls the iterator for every value in the tree between lo and
+// hi in descending order, starting from hi, until iterator returns false.  See
+// AscendBetween.
+func (t *BTree) DescendBetween(lo, hi *Item, loInclusive, hiInclusive bool, iterator ItemIterator) {
+	if t.root == nil {
+		return
+	}
+	less := func(a, b *Item) bool { return t.less(b, a) }
+	t.root.iterate(t.order(descend), hi, nil, true, false, between(less, hi, lo, hiInclusive, loInclusive, iterator))
+}
+
+// between wraps iter for a walk in the order of less that starts from the
+// first item not less than from: the wrapper skips the items equal to from
+// unless fromInclusive, and stops at the first item past to.
+func between(less func(a, b *Item) bool, from, to *Item, fromInclusive, toInclusive bool, iter ItemIterator) ItemIterator {
+	return func(i *Item) bool {
+		if !fromInclusive && from != nil && !less(from, i) {
+			return true
+		}
+		if to != nil && (less(to, i) || !toInclusive && !less(i, to)) {
+			return false
+		}
+		return iter(i)
+	}
+}
+
+// AscendKeys calls the iterator for every key in the tree in ascending order,
+// until iterator returns false.  It is a leaner alternative to Ascend for
+// scans that only need keys: there are no range checks, and keys are passed
+// by value, so the callback never dereferences items or their payloads.
+func (t *BTree) AscendKeys(iterator KeyIterator) {
+	if t.root == nil {
+		return
+	}
+	if t.reverse {
+		t.root.descendKeys(iterator)
+		return
+	}
+	t.root.ascendKeys(iterator)
+}
+
+// DescendKeys calls the iterator for every key in the tree in descending
+// order, until iterator returns false.  See AscendKeys.
+func (t *BTree) DescendKeys(iterator KeyIterator) {
+	if t.root == nil {
+		return
+	}
+	if t.reverse {
+		t.root.ascendKeys(iterator)
+		return
+	}
+	t.root.descendKeys(iterator)
+}
+
+func (n *node) ascendKeys(iter KeyIterator) bool {
+	if len(n.children) == 0 {
+		for _, item := range n.items {
+			if !iter(item.Key) {
+				return false
+			}
+		}
+		return true
+	}
+	for i, item := range n.items {
+		if !n.children[i].ascendKeys(iter) || !iter(item.Key) {
+			return false
+		}
+	}
+	return n.children[len(n.items)].ascendKeys(iter)
+}
+
+func (n *node) descendKeys(iter KeyIterator) bool {
+	if len(n.children) == 0 {
+		for i := len(n.items) - 1; i >= 0; i-- {
+			if !iter(n.items[i].Key) {
+				return false
+			}
+		}
+		return true
+	}
+	for i := len(n.items) - 1; i >= 0; i-- {
+		if !n.children[i+1].descendKeys(iter) || !iter(n.items[i].Key) {
+			return false
+		}
+	}
+	return n.children[0].descendKeys(iter)
+}
+
+// UnsafeAscendBatches calls the iterator with the items of the tree in
+// ascending key order, a batch at a time, until iterator returns false.  The
+// batches are the nodes' own item slices rather than copies: each leaf is
+// passed whole, and each item of an inner node on its own between the batches
+// of its children.  Trees created by NewReverse are visited in key order too.
+//
+// This is an expert API for scans whose per-item callback overhead matters.
+// The batches are only valid during the call and until the tree is next
+// modified.  Writing to a batch corrupts the tree and every clone sharing its
+// nodes; appending to one is harmless, as its capacity ends at its length.
+func (t *BTree) UnsafeAscendBatches(iterator func(items []*Item) bool) {
+	if t.root != nil {
+		t.root.ascendBatches(iterator)
+	}
+}
+
+func (n *node) ascendBatches(iter func([]*Item) bool) bool {
+	if len(n.children) == 0 {
+		return iter(n.items[:len(n.items):len(n.items)])
+	}
+	for i := range n.items {
+		if !n.children[i].ascendBatches(iter) || !iter(n.items[i : i+1 : i+1]) {
+			return false
+		}
+	}
+	return n.children[len(n.items)].ascendBatches(iter)
+}
+
+// AscendChunks calls the iterator with the items of the tree in ascending
+// order, a chunk at a time, until iterator returns false.  Each chunk holds
+// the items of a leaf along with the inner items leading up to it, so there
+// is about one call per degree items, which saves most of the per-item
+// overhead of Ascend in long scans.
+//
+// A chunk is a copy, so writing to it does not affect the tree, but the same
+// slice is reused for every call; copy the items to keep them.
+func (t *BTree) AscendChunks(iterator func(items []*Item) bool) {
+	t.chunks(t.order(ascend), iterator)
+}
+
+// DescendChunks calls the iterator with the items of the tree in descending
+// order, a chunk at a time, until iterator returns false.  See AscendChunks.
+func (t *BTree) DescendChunks(iterator func(items []*Item) bool) {
+	t.chunks(t.order(descend), iterator)
+}
+
+func (t *BTree) chunks(dir direction, iter func([]*Item) bool) {
+	if t.root != nil {
+		t.root.chunks(dir, make([]*Item, 0, t.maxItems()+t.root.height()), iter)
+	}
+}
+
+// chunks passes the items of the subtree to iter in direction dir, a leaf at
+// a time.  Inner items are collected in buf until the next leaf comes along.
+// It returns buf, emptied, and whether iter asked to go on.
+func (n *node) chunks(dir direction, buf []*Item, iter func([]*Item) bool) ([]*Item, bool) {
+	if len(n.children) == 0 {
+		if dir == ascend {
+			buf = append(buf, n.items...)
+		} else {
+			for i := len(n.items) - 1; i >= 0; i-- {
+				buf = append(buf, n.items[i])
+			}
+		}
+		return buf[:0], iter(buf)
+	}
+	var ok bool
+	for k := range n.items {
+		i, child := k, k
+		if dir == descend {
+			i = len(n.items) - 1 - k
+			child = i + 1
+		}
+		if buf, ok = n.children[child].chunks(dir, buf, iter); !ok {
+			return buf, false
+		}
+		buf = append(buf, n.items[i])
+	}
+	last := len(n.items)
+	if dir == descend {
+		last = 0
+	}
+	return n.children[last].chunks(dir, buf, iter)
 }
 
 // Get looks for the key item in the tree, returning it.  It returns nil if
-// unable to find that item.
+// unable to find that item.  For trees created by NewMulti, any one of the
+// equal items may be returned; use GetAll to get all of them.
 func (t *BTree) Get(key *Item) *Item {
 	if t.root == nil {
 		return nil
@@ -834,17 +1459,239 @@ func (t *BTree) Get(key *Item) *Item {
 
 // Min returns the smallest item in the tree, or nil if the tree is empty.
 func (t *BTree) Min() *Item {
-	return min(t.root)
+	if t.reverse {
+		return t.last
+	}
+	return t.first
 }
 
 // Max returns the largest item in the tree, or nil if the tree is empty.
 func (t *BTree) Max() *Item {
-	return max(t.root)
+	if t.reverse {
+		return t.first
+	}
+	return t.last
+}
+
+// Floor returns the greatest item in the tree that is less than or equal to
+// item, or nil if there is none.
+func (t *BTree) Floor(item *Item) *Item {
+	if t.root == nil {
+		return nil
+	}
+	if t.reverse {
+		return t.root.ceiling(item)
+	}
+	return t.root.floor(item)
+}
+
+// Ceiling returns the least item in the tree that is greater than or equal to
+// item, or nil if there is none.
+func (t *BTree) Ceiling(item *Item) *Item {
+	if t.root == nil {
+		return nil
+	}
+	if t.reverse {
+		return t.root.floor(item)
+	}
+	return t.root.ceiling(item)
+}
+
+// Next returns the least item in the tree that is greater than item, or nil
+// if there is none.  item itself need not be in the tree.
+func (t *BTree) Next(item *Item) *Item {
+	if t.root == nil {
+		return nil
+	}
+	if t.reverse {
+		return t.root.predecessor(item)
+	}
+	return t.root.successor(item)
+}
+
+// Prev returns the greatest item in the tree that is less than item, or nil
+// if there is none.  item itself need not be in the tree.
+func (t *BTree) Prev(item *Item) *Item {
+	if t.root == nil {
+		return nil
+	}
+	if t.reverse {
+		return t.root.successor(item)
+	}
+	return t.root.predecessor(item)
 }
 
-// Has returns true if the given key is in the tree.
+// FirstMatch returns the least item in the tree for which pred is true, or
+// nil if there is none.  pred must be monotone over the key order: false for
+// every item before some point and true for every item from there on.  Like
+// sort.Search, only O(log n) items are tested, which makes this a cheap way
+// to find thresholds on properties derived from the keys.
+func (t *BTree) FirstMatch(pred func(*Item) bool) *Item {
+	if t.root == nil {
+		return nil
+	}
+	if t.reverse {
+		return t.root.lastMatch(pred)
+	}
+	return t.root.firstMatch(pred)
+}
+
+// Has returns true if the given key is in the tree.  It descends the tree
+// without allocating, so it is cheap enough for hot paths.
 func (t *BTree) Has(key *Item) bool {
-	return t.Get(key) != nil
+	for n := t.root; n != nil; {
+		// Inlined items.find, without the closure.
+		lo, hi := 0, len(n.items)
+		for lo < hi {
+			m := int(uint(lo+hi) >> 1)
+			if key.Less(n.items[m]) {
+				hi = m
+			} else {
+				lo = m + 1
+			}
+		}
+		if lo > 0 && !n.items[lo-1].Less(key) {
+			return true
+		}
+		if len(n.children) == 0 {
+			return false
+		}
+		n = n.children[lo]
+	}
+	return false
+}
+
+// Set stores value as the Payload of the item with the given key, adding such
+// an item if the tree doesn't have one yet, so that the tree can be used as an
+// ordered map.  The item previously stored under key is returned, or nil if
+// there was none.
+func (t *BTree) Set(key float64, value interface{}) *Item {
+	return t.ReplaceOrInsert(&Item{Key: key, Payload: value})
+}
+
+// GetValue returns the Payload of the item with the given key.  The boolean
+// reports whether such an item was found.
+func (t *BTree) GetValue(key float64) (interface{}, bool) {
+	item := t.Get(&Item{Key: key})
+	if item == nil {
+		return nil, false
+	}
+	return item.Payload, true
+}
+
+// Add adds delta to the int64 Payload of the item with the given key and
+// returns the result, inserting an item holding just delta if there is none.
+// The lookup and the update happen in a single descent, as with Upsert.  A nil
+// Payload counts as 0; any other payload that is not an int64 panics.
+//
+// The item is replaced rather than updated in place, so clones sharing it
+// keep their values.  Trees created by NewMulti keep no single item per key
+// to add to, so Add panics on them.
+func (t *BTree) Add(key *Item, delta int64) int64 {
+	if t.multi {
+		panic("btree: Add on a tree created by NewMulti")
+	}
+	sum := delta
+	t.Upsert(&Item{Key: key.Key, Payload: delta}, func(existing, new *Item) *Item {
+		if existing.Payload != nil {
+			sum += existing.Payload.(int64)
+		}
+		return &Item{Key: existing.Key, SubTree: existing.SubTree, Payload: sum}
+	})
+	return sum
+}
+
+// AddFloat is Add for float64 payloads.
+func (t *BTree) AddFloat(key *Item, delta float64) float64 {
+	if t.multi {
+		panic("btree: AddFloat on a tree created by NewMulti")
+	}
+	sum := delta
+	t.Upsert(&Item{Key: key.Key, Payload: delta}, func(existing, new *Item) *Item {
+		if existing.Payload != nil {
+			sum += existing.Payload.(float64)
+		}
+		return &Item{Key: existing.Key, SubTree: existing.SubTree, Payload: sum}
+	})
+	return sum
+}
+
+// Update calls fn with a copy of the item equal to key, which then replaces
+// that item in place, and reports whether there was such an item.  It is
+// meant for changing an item's Payload or SubTree without the cost of
+// deleting and reinserting it; the tree's shape does not change.  For trees
+// created by NewMulti, the item updated is the one Get returns.
+//
+// fn must not change the key of the item, or Update panics.  As with Add,
+// the item is replaced rather than modified, so clones sharing it keep the
+// old one.
+func (t *BTree) Update(key *Item, fn func(*Item)) bool {
+	if t.root == nil || t.root.get(key) == nil {
+		return false
+	}
+	t.root = t.root.mutableFor(t.cow)
+	old, item := t.root.update(key, fn)
+	if t.first == old {
+		t.first = item
+	}
+	if t.last == old {
+		t.last = item
+	}
+	return true
+}
+
+// update replaces the item equal to key in the subtree, which must hold one,
+// with the copy of it modified by fn, returning the old and new items.
+func (n *node) update(key *Item, fn func(*Item)) (*Item, *Item) {
+	i, found := n.items.find(key)
+	if !found {
+		return n.mutableChild(i).update(key, fn)
+	}
+	old := n.items[i]
+	item := *old
+	fn(&item)
+	if item.Key != old.Key {
+		panic("Update changed the key of an item")
+	}
+	n.items[i] = &item
+	return old, &item
+}
+
+// CountRange returns the number of items in the tree within the range
+// [greaterOrEqual, lessThan).  A nil bound leaves that side of the range open.
+// Only nodes straddling the bounds are searched; nodes in between are counted
+// whole, so this takes O(log n + count/degree).
+func (t *BTree) CountRange(greaterOrEqual, lessThan *Item) int {
+	if t.root == nil {
+		return 0
+	}
+	greaterOrEqual, lessThan, ok := t.bounds(greaterOrEqual, lessThan)
+	if !ok {
+		return 0
+	}
+	return t.root.count(greaterOrEqual, lessThan)
+}
+
+// bounds converts the range [greaterOrEqual, lessThan) of the tree's order
+// into the same range in ascending key order, where a nil bound leaves that
+// side of the range open.  For reversed trees, the range holds the items
+// after lessThan up to and including greaterOrEqual in key order, so its
+// bounds are the items that follow each of them; false is returned if no item
+// follows lessThan, which leaves the range empty.
+func (t *BTree) bounds(greaterOrEqual, lessThan *Item) (*Item, *Item, bool) {
+	if !t.reverse || t.root == nil {
+		return greaterOrEqual, lessThan, true
+	}
+	var lo, hi *Item
+	if lessThan != nil {
+		if lo = t.root.successor(lessThan); lo == nil {
+			return nil, nil, false
+		}
+	}
+	if greaterOrEqual != nil {
+		hi = t.root.successor(greaterOrEqual)
+	}
+	return lo, hi, true
 }
 
 // Len returns the number of items currently in the tree.
@@ -855,7 +1702,9 @@ func (t *BTree) Len() int {
 // Clear removes all items from the btree.  If addNodesToFreelist is true,
 // t's nodes are added to its freelist as part of this call, until the freelist
 // is full.  Otherwise, the root node is simply dereferenced and the subtree
-// left to Go's normal GC processes.
+// left to Go's normal GC processes.  Trees created by NewWithArena also stop
+// carving nodes from their current slab, so that the slabs are collected as
+// whole blocks once no tree references their nodes.
 //
 // This can be much faster
 // than calling Delete on all elements, because that requires finding/removing
@@ -873,10 +1722,113 @@ func (t *BTree) Len() int {
 //       iterated over looking for nodes to add to the freelist, and due to
 //       ownership, none are.
 func (t *BTree) Clear(addNodesToFreelist bool) {
+	if t.root != nil {
+		t.releaseSubtree(t.root)
+	}
 	if t.root != nil && addNodesToFreelist {
 		t.root.reset(t.cow)
 	}
 	t.root, t.length = nil, 0
+	t.first, t.last = nil, nil
+	if f := t.cow.freelist; f.arena != nil {
+		f.mu.Lock()
+		f.arena.release()
+		f.mu.Unlock()
+	}
+}
+
+// ClearFunc removes all items from the btree like Clear(true), calling fn for
+// each removed item in ascending order so that resources held by items can be
+// released along the way.  Unlike Clear, it always visits the whole tree.
+//
+// Items shared with clones of t are passed to fn too, even though the clones
+// still hold them.
+func (t *BTree) ClearFunc(fn func(*Item)) {
+	if t.root != nil {
+		t.root.clearFunc(t.cow, func(i *Item) {
+			fn(i)
+			if t.itemPool != nil {
+				t.itemPool.Release(i)
+			}
+		})
+		t.root = nil
+	}
+	t.Clear(false)
+}
+
+// clearFunc calls fn for each item of the subtree in order, returning its
+// nodes to the freelist.
+func (n *node) clearFunc(c *copyOnWriteContext, fn func(*Item)) {
+	for i, item := range n.items {
+		if len(n.children) > 0 {
+			n.children[i].clearFunc(c, fn)
+		}
+		fn(item)
+	}
+	if len(n.children) > 0 {
+		n.children[len(n.children)-1].clearFunc(c, fn)
+	}
+	c.freeNode(n)
+}
+
+// Reclaimer hands the nodes of a tree cleared by ClearIncremental back to its
+// freelist a batch at a time.  It works on nodes that are no longer part of
+// the tree, so it can run concurrently with further use of the tree, e.g. in a
+// background goroutine:
+//
+//	r := tr.ClearIncremental(1024)
+//	go func() {
+//		for r.Next() {
+//			time.Sleep(time.Millisecond)
+//		}
+//	}()
+type Reclaimer struct {
+	cow   *copyOnWriteContext
+	batch int
+	nodes []*node
+	// pool receives the items of the nodes released, if set.
+	pool *ItemPool
+}
+
+// ClearIncremental removes all items from the tree at once, like Clear, but
+// leaves releasing its nodes to the returned Reclaimer, batch nodes per call
+// to Next.  Nodes are added to the freelist until it is full and otherwise
+// unlinked from each other, so that a huge tree becomes garbage gradually
+// instead of in one piece.
+func (t *BTree) ClearIncremental(batch int) *Reclaimer {
+	if batch <= 0 {
+		batch = 1
+	}
+	r := &Reclaimer{cow: t.cow, batch: batch, pool: t.itemPool}
+	if t.root != nil {
+		r.nodes = append(r.nodes, t.root)
+	}
+	t.root, t.length = nil, 0
+	t.first, t.last = nil, nil
+	return r
+}
+
+// Next releases up to the next batch of nodes, returning true if there are
+// nodes left to release.
+func (r *Reclaimer) Next() bool {
+	for i := 0; i < r.batch && len(r.nodes) > 0; i++ {
+		n := r.nodes[len(r.nodes)-1]
+		r.nodes[len(r.nodes)-1] = nil
+		r.nodes = r.nodes[:len(r.nodes)-1]
+		// Nodes owned by another tree are still in use, along with everything
+		// below them.
+		if n.cow != r.cow {
+			continue
+		}
+		r.nodes = append(r.nodes, n.children...)
+		if r.pool != nil {
+			for _, item := range n.items {
+				r.pool.Release(item)
+			}
+		}
+		r.cow.freeNode(n)
+	}
+	return len(r.nodes) > 0
 }
 
 // reset returns a subtree to the freelist.  It breaks out immediately if the
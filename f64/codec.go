@@ -0,0 +1,249 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package f64
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ErrBadEncoding is returned when decoding data that was not written by the
+// matching encoder, or was corrupted since.
+var ErrBadEncoding = errors.New("btree: malformed encoding")
+
+// ItemCodec encodes and decodes single items, so that trees can be written by
+// MarshalBinary and read back by UnmarshalBinary.  Payloads are opaque to the
+// tree, so trees with payloads need a codec that knows how to encode them.
+type ItemCodec interface {
+	// AppendItem appends the encoding of item to dst and returns the result.
+	AppendItem(dst []byte, item *Item) ([]byte, error)
+	// DecodeItem returns the item encoded in all of data.
+	DecodeItem(data []byte) (*Item, error)
+}
+
+// DefaultItemCodec is the codec of trees that were not given one with
+// SetItemCodec, including the trees gob creates while decoding.  Programs
+// storing payloads in gob-encoded trees set it once, before any decoding.
+var DefaultItemCodec ItemCodec = KeyCodec{}
+
+// SetItemCodec makes the tree encode and decode its items with c, or with
+// DefaultItemCodec if c is nil.
+func (t *BTree) SetItemCodec(c ItemCodec) {
+	t.codec = c
+}
+
+func (t *BTree) itemCodec() ItemCodec {
+	if t.codec != nil {
+		return t.codec
+	}
+	return DefaultItemCodec
+}
+
+// KeyCodec is an ItemCodec for trees used as sets, which encodes keys only.
+// Encoding an item with a payload or subtree fails.
+type KeyCodec struct{}
+
+// AppendItem implements ItemCodec.
+func (KeyCodec) AppendItem(dst []byte, item *Item) ([]byte, error) {
+	if item.Payload != nil || item.SubTree != nil {
+		return dst, fmt.Errorf("btree: KeyCodec cannot encode the payload of item %v", item.Key)
+	}
+	return AppendKey(dst, item.Key), nil
+}
+
+// DecodeItem implements ItemCodec.
+func (KeyCodec) DecodeItem(data []byte) (*Item, error) {
+	key, n, err := DecodeKey(data)
+	if err != nil {
+		return nil, err
+	}
+	if n != len(data) {
+		return nil, ErrBadEncoding
+	}
+	return &Item{Key: key}, nil
+}
+
+// AppendKey appends the encoding of key to dst and returns the result, for
+// use by ItemCodec implementations.  Strings are written as their length
+// followed by their bytes, and numbers in big-endian order.
+func AppendKey(dst []byte, key interface{}) []byte {
+	if s, ok := key.(string); ok {
+		dst = appendUvarint(dst, uint64(len(s)))
+		return append(dst, s...)
+	}
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, key)
+	return append(dst, buf.Bytes()...)
+}
+
+// DecodeKey decodes the key that AppendKey wrote at the start of data,
+// returning it along with the number of bytes it took.
+func DecodeKey(data []byte) (key float64, n int, err error) {
+	if p, ok := interface{}(&key).(*string); ok {
+		size, m := binary.Uvarint(data)
+		if m <= 0 || uint64(len(data)-m) < size {
+			return key, 0, ErrBadEncoding
+		}
+		*p = string(data[m : m+int(size)])
+		return key, m + int(size), nil
+	}
+	size := binary.Size(key)
+	if len(data) < size {
+		return key, 0, ErrBadEncoding
+	}
+	if err := binary.Read(bytes.NewReader(data[:size]), binary.BigEndian, &key); err != nil {
+		return key, 0, err
+	}
+	return key, size, nil
+}
+
+func appendUvarint(dst []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(dst, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+// binaryVersion is the version of the format written by MarshalBinary and
+// Snapshot.
+const binaryVersion = 1
+
+// Flags of the format written by MarshalBinary and Snapshot.
+const (
+	binaryReverse = 1 << iota
+	binaryMulti
+)
+
+// MarshalBinary implements encoding.BinaryMarshaler, which also lets trees be
+// encoded with encoding/gob.  The degree and order of the tree are written,
+// followed by its items in key order, each encoded by the tree's codec.
+func (t *BTree) MarshalBinary() ([]byte, error) {
+	data := t.appendHeader(nil)
+	codec := t.itemCodec()
+	var err error
+	t.walk(func(i *Item) bool {
+		data, err = appendItem(data, codec, i)
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, replacing the
+// contents of the tree, as well as its degree and order, with those encoded
+// in data.  The items are decoded by the tree's codec, and packed into nodes
+// as by a Builder.  The tree keeps its codec and item pool.
+func (t *BTree) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	b, flags, count, err := readHeader(r)
+	if err != nil {
+		return err
+	}
+	if err := decodeItems(b, t.itemCodec(), data[len(data)-r.Len():]); err != nil {
+		return err
+	}
+	return t.finishDecoding(b, flags, count)
+}
+
+// appendHeader appends the degree, order and length of the tree to dst.
+func (t *BTree) appendHeader(dst []byte) []byte {
+	var flags byte
+	if t.reverse {
+		flags |= binaryReverse
+	}
+	if t.multi {
+		flags |= binaryMulti
+	}
+	dst = append(dst, binaryVersion, flags)
+	dst = appendUvarint(dst, uint64(t.degree))
+	return appendUvarint(dst, uint64(t.length))
+}
+
+// readHeader reads the header written by appendHeader, returning a Builder for
+// the tree it describes along with its flags and length.
+func readHeader(r io.ByteReader) (b *Builder, flags byte, count uint64, err error) {
+	version, err := r.ReadByte()
+	if err != nil || version != binaryVersion {
+		return nil, 0, 0, ErrBadEncoding
+	}
+	if flags, err = r.ReadByte(); err != nil {
+		return nil, 0, 0, ErrBadEncoding
+	}
+	degree, err := binary.ReadUvarint(r)
+	if err != nil || degree < 2 || degree > math.MaxInt32 {
+		return nil, 0, 0, ErrBadEncoding
+	}
+	if count, err = binary.ReadUvarint(r); err != nil {
+		return nil, 0, 0, ErrBadEncoding
+	}
+	b = NewBuilder(int(degree))
+	b.tree.multi = flags&binaryMulti != 0
+	return b, flags, count, nil
+}
+
+// appendItem appends the encoding of item by codec to dst, preceded by its
+// length.
+func appendItem(dst []byte, codec ItemCodec, item *Item) ([]byte, error) {
+	n := len(dst)
+	dst = append(dst, make([]byte, binary.MaxVarintLen64)...)
+	dst, err := codec.AppendItem(dst, item)
+	if err != nil {
+		return dst[:n], err
+	}
+	size := len(dst) - n - binary.MaxVarintLen64
+	m := binary.PutUvarint(dst[n:], uint64(size))
+	return append(dst[:n+m], dst[n+binary.MaxVarintLen64:]...), nil
+}
+
+// decodeItems appends to b all the items appended to data by appendItem.
+func decodeItems(b *Builder, codec ItemCodec, data []byte) error {
+	for len(data) > 0 {
+		size, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data)-n) < size {
+			return ErrBadEncoding
+		}
+		item, err := codec.DecodeItem(data[n : n+int(size)])
+		if err != nil {
+			return err
+		}
+		if item == nil || b.last != nil && b.outOfOrder(item) {
+			return ErrBadEncoding
+		}
+		b.Append(item)
+		data = data[n+int(size):]
+	}
+	return nil
+}
+
+// finishDecoding replaces t with the tree built by b, once all count items
+// are appended.
+func (t *BTree) finishDecoding(b *Builder, flags byte, count uint64) error {
+	if uint64(b.tree.length) != count {
+		return ErrBadEncoding
+	}
+	out := b.Finish()
+	out.reverse = flags&binaryReverse != 0
+	out.codec, out.itemPool = t.codec, t.itemPool
+	*t = *out
+	return nil
+}
@@ -0,0 +1,182 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package f64
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Dump writes the node structure of the tree to w, one line per level from
+// the root down, listing the keys of each node in brackets:
+//
+//	0: [3 7]
+//	1: [0 1 2] [4 5 6] [8 9]
+//
+// It is meant for debugging tree shapes, e.g. when tuning the degree.
+func (t *BTree) Dump(w io.Writer) {
+	if t.root == nil {
+		return
+	}
+	level := []*node{t.root}
+	for depth := 0; len(level) > 0; depth++ {
+		var next []*node
+		nodes := make([]string, len(level))
+		for i, n := range level {
+			keys := make([]string, len(n.items))
+			for j, item := range n.items {
+				keys[j] = fmt.Sprint(item.Key)
+			}
+			nodes[i] = "[" + strings.Join(keys, " ") + "]"
+			next = append(next, n.children...)
+		}
+		fmt.Fprintf(w, "%d: %s\n", depth, strings.Join(nodes, " "))
+		level = next
+	}
+}
+
+// LoadDump reads a tree in the format written by Dump and returns a new tree
+// with the given degree and exactly that node structure.  Together with Dump,
+// this lets tests keep the shape of a tree as a fixture, for instance one that
+// once triggered a rebalancing bug, rather than only its items.
+//
+// Items are created with nil payloads.  Keys are parsed with fmt.Sscan, so
+// string keys must not contain spaces or brackets.  An error is returned if
+// the input is malformed or does not describe a valid B-Tree of the degree.
+func LoadDump(degree int, r io.Reader) (*BTree, error) {
+	var levels [][]*NodeLiteral
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		prefix := fmt.Sprintf("%d:", len(levels))
+		if !strings.HasPrefix(line, prefix) {
+			return nil, fmt.Errorf("btree: level %d: line does not start with %q", len(levels), prefix)
+		}
+		level, err := parseDumpLevel(line[len(prefix):])
+		if err != nil {
+			return nil, fmt.Errorf("btree: level %d: %v", len(levels), err)
+		}
+		levels = append(levels, level)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(levels) == 0 {
+		return New(degree), nil
+	}
+	if len(levels[0]) != 1 {
+		return nil, fmt.Errorf("btree: level 0 has %d nodes, want 1", len(levels[0]))
+	}
+	// Hand out the nodes of each level to the nodes of the level above, in
+	// order, as many to each as it has gaps between its keys.  Going from the
+	// bottom up, every child is complete by the time it is copied into its
+	// parent.
+	for depth := len(levels) - 1; depth > 0; depth-- {
+		next := levels[depth]
+		for _, parent := range levels[depth-1] {
+			k := len(parent.Keys) + 1
+			if len(next) < k {
+				return nil, fmt.Errorf("btree: level %d has too few nodes", depth)
+			}
+			for _, child := range next[:k] {
+				parent.Children = append(parent.Children, *child)
+			}
+			next = next[k:]
+		}
+		if len(next) > 0 {
+			return nil, fmt.Errorf("btree: level %d has %d nodes too many", depth, len(next))
+		}
+	}
+	return buildNodes(degree, *levels[0][0])
+}
+
+// parseDumpLevel parses the bracketed nodes of one line written by Dump.
+func parseDumpLevel(s string) ([]*NodeLiteral, error) {
+	var level []*NodeLiteral
+	for {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return level, nil
+		}
+		end := strings.IndexByte(s, ']')
+		if s[0] != '[' || end < 0 {
+			return nil, fmt.Errorf("malformed node %q", s)
+		}
+		n := &NodeLiteral{}
+		for _, field := range strings.Fields(s[1:end]) {
+			var key float64
+			if _, err := fmt.Sscan(field, &key); err != nil {
+				return nil, fmt.Errorf("bad key %q: %v", field, err)
+			}
+			n.Keys = append(n.Keys, key)
+		}
+		level = append(level, n)
+		s = s[end+1:]
+	}
+}
+
+// dotEscaper escapes the characters that are special in DOT record labels.
+var dotEscaper = strings.NewReplacer(
+	`\`, `\\`, `"`, `\"`, `{`, `\{`, `}`, `\}`, `|`, `\|`, `<`, `\<`, `>`, `\>`,
+)
+
+// DumpDot writes the structure of the tree to w as a Graphviz DOT graph, with
+// one record per node and an edge from each child pointer to the child it
+// points to.  Items are labeled with labeler, or with their keys if labeler is
+// nil.  Render the result with e.g. "dot -Tsvg".
+func (t *BTree) DumpDot(w io.Writer, labeler func(*Item) string) {
+	if labeler == nil {
+		labeler = func(i *Item) string { return fmt.Sprint(i.Key) }
+	}
+	fmt.Fprintln(w, "digraph btree {")
+	fmt.Fprintln(w, "\tnode [shape=record];")
+	if t.root != nil {
+		id := 0
+		t.root.dumpDot(w, labeler, &id)
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// dumpDot writes the subtree rooted at n, naming its nodes after the counter
+// at id, and returns the name of n.
+func (n *node) dumpDot(w io.Writer, labeler func(*Item) string, id *int) string {
+	name := fmt.Sprintf("n%d", *id)
+	*id++
+	fields := make([]string, 0, len(n.items)+len(n.children))
+	for i, item := range n.items {
+		if len(n.children) > 0 {
+			fields = append(fields, fmt.Sprintf("<c%d>", i))
+		}
+		fields = append(fields, dotEscaper.Replace(labeler(item)))
+	}
+	if len(n.children) > 0 {
+		fields = append(fields, fmt.Sprintf("<c%d>", len(n.items)))
+	}
+	fmt.Fprintf(w, "\t%s [label=\"%s\"];\n", name, strings.Join(fields, "|"))
+	for i, c := range n.children {
+		child := c.dumpDot(w, labeler, id)
+		fmt.Fprintf(w, "\t%s:c%d -> %s;\n", name, i, child)
+	}
+	return name
+}
@@ -0,0 +1,94 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package f64
+
+import "sync"
+
+// ItemPool recycles items, the way a FreeList recycles nodes, for workloads
+// that insert and delete items at a high rate.  It is safe for concurrent use
+// by multiple goroutines.
+type ItemPool struct {
+	mu    sync.Mutex
+	items []*Item
+}
+
+// NewItemPool creates a new item pool holding at most size items.
+func NewItemPool(size int) *ItemPool {
+	return &ItemPool{items: make([]*Item, 0, size)}
+}
+
+// Acquire returns an item with the given key and no payload, recycled if the
+// pool has one.
+func (p *ItemPool) Acquire(key float64) *Item {
+	p.mu.Lock()
+	index := len(p.items) - 1
+	if index < 0 {
+		p.mu.Unlock()
+		return &Item{Key: key}
+	}
+	item := p.items[index]
+	p.items[index] = nil
+	p.items = p.items[:index]
+	p.mu.Unlock()
+	item.Key = key
+	return item
+}
+
+// Release returns item to the pool, to be handed out again by Acquire, unless
+// the pool is full.  Its payload and subtree are cleared so the GC can collect
+// them.  item must not be used after it has been released.
+func (p *ItemPool) Release(item *Item) {
+	*item = Item{}
+	p.mu.Lock()
+	if len(p.items) < cap(p.items) {
+		p.items = append(p.items, item)
+	}
+	p.mu.Unlock()
+}
+
+// SetItemPool makes the tree release to p the items it drops without handing
+// them back to the caller, or stops it doing so if p is nil.  Those are the
+// items removed by DeleteAll, DeleteRange and DeleteBatch, which only return
+// counts, and the items dropped by Clear, ClearFunc (after fn has seen them)
+// and the Reclaimer of ClearIncremental, as well as the items evicted by a
+// Window or expired by an ExpiringTree.  With a pool set, Clear visits every
+// item, so it takes O(tree size).
+//
+// Items returned by Delete, DeleteMin, DeleteMax, DeleteOne, DeleteMatch,
+// DeleteMinN, DeleteMaxN and ReplaceOrInsert belong to the caller, intact;
+// they can be recycled with p.Release once the caller is done with them.
+//
+// Items are only safe to release while no other tree holds them, so this must
+// not be used with trees that are cloned.
+func (t *BTree) SetItemPool(p *ItemPool) {
+	t.itemPool = p
+}
+
+// releaseSubtree releases the items of the subtree rooted at n to the tree's
+// item pool, if it has one.  The subtree must already be cut off from the
+// tree.
+func (t *BTree) releaseSubtree(n *node) {
+	if t.itemPool == nil {
+		return
+	}
+	n.iterate(ascend, nil, nil, false, false, func(i *Item) bool {
+		t.itemPool.Release(i)
+		return true
+	})
+}
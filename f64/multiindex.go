@@ -0,0 +1,151 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package f64
+
+// IndexFunc extracts the key under which a MultiIndex secondary index files an
+// item.  It must return the same key for as long as the item is in the index.
+type IndexFunc func(*Item) float64
+
+// MultiIndex keeps a primary tree of items along with any number of secondary
+// indexes over the same items, each ordering them by a key extracted by an
+// IndexFunc, and keeps them all in sync as items are inserted and deleted.
+//
+// Each secondary index is a tree with one item per distinct secondary key,
+// whose SubTree holds the primary items filed under that key, ordered by
+// their primary key.
+type MultiIndex struct {
+	degree    int
+	primary   *BTree
+	funcs     []IndexFunc
+	secondary []*BTree
+}
+
+// NewMultiIndex creates an empty MultiIndex whose trees have the given degree,
+// with one secondary index per IndexFunc, numbered from 0 in the order given.
+func NewMultiIndex(degree int, funcs ...IndexFunc) *MultiIndex {
+	m := &MultiIndex{degree: degree, primary: New(degree), funcs: funcs}
+	for range funcs {
+		m.secondary = append(m.secondary, New(degree))
+	}
+	return m
+}
+
+// Primary returns the primary tree, for reading.  Modifying it directly would
+// leave the secondary indexes out of sync.
+func (m *MultiIndex) Primary() *BTree {
+	return m.primary
+}
+
+// Len returns the number of items in the index.
+func (m *MultiIndex) Len() int {
+	return m.primary.Len()
+}
+
+// Get looks for the item with the given primary key, returning it.  It returns
+// nil if unable to find that item.
+func (m *MultiIndex) Get(key *Item) *Item {
+	return m.primary.Get(key)
+}
+
+// ReplaceOrInsert adds the given item to the primary tree and files it in
+// every secondary index.  If an item with an equal primary key was already
+// present, it is removed from all indexes and returned.  Otherwise, nil is
+// returned.
+func (m *MultiIndex) ReplaceOrInsert(item *Item) *Item {
+	out := m.primary.ReplaceOrInsert(item)
+	for i, f := range m.funcs {
+		if out != nil {
+			m.unfile(i, out)
+		}
+		key := &Item{Key: f(item)}
+		entry := m.secondary[i].Get(key)
+		if entry == nil {
+			entry = key
+			entry.SubTree = New(m.degree)
+			m.secondary[i].ReplaceOrInsert(entry)
+		}
+		entry.SubTree.ReplaceOrInsert(item)
+	}
+	return out
+}
+
+// Delete removes the item with the given primary key from all indexes,
+// returning it.  If no such item exists, returns nil.
+func (m *MultiIndex) Delete(key *Item) *Item {
+	out := m.primary.Delete(key)
+	if out != nil {
+		for i := range m.funcs {
+			m.unfile(i, out)
+		}
+	}
+	return out
+}
+
+// unfile removes item from secondary index i, along with its entry there if
+// no other item is filed under the same key.
+func (m *MultiIndex) unfile(i int, item *Item) {
+	key := &Item{Key: m.funcs[i](item)}
+	entry := m.secondary[i].Get(key)
+	if entry == nil {
+		return
+	}
+	entry.SubTree.Delete(item)
+	if entry.SubTree.Len() == 0 {
+		m.secondary[i].Delete(key)
+	}
+}
+
+// GetByIndex returns the items filed under key in secondary index i, in order
+// of primary key.
+func (m *MultiIndex) GetByIndex(i int, key float64) (out []*Item) {
+	entry := m.secondary[i].Get(&Item{Key: key})
+	if entry == nil {
+		return nil
+	}
+	entry.SubTree.Ascend(func(item *Item) bool {
+		out = append(out, item)
+		return true
+	})
+	return
+}
+
+// AscendIndex calls the iterator for every item filed in secondary index i
+// under a key within the range [greaterOrEqual, lessThan), ordered by that key
+// and then by primary key, until iterator returns false.  A nil bound leaves
+// that side of the range open.
+func (m *MultiIndex) AscendIndex(i int, greaterOrEqual, lessThan *Item, iterator ItemIterator) {
+	visit := func(entry *Item) bool {
+		more := true
+		entry.SubTree.Ascend(func(item *Item) bool {
+			more = iterator(item)
+			return more
+		})
+		return more
+	}
+	switch {
+	case greaterOrEqual == nil && lessThan == nil:
+		m.secondary[i].Ascend(visit)
+	case greaterOrEqual == nil:
+		m.secondary[i].AscendLessThan(lessThan, visit)
+	case lessThan == nil:
+		m.secondary[i].AscendGreaterOrEqual(greaterOrEqual, visit)
+	default:
+		m.secondary[i].AscendRange(greaterOrEqual, lessThan, visit)
+	}
+}
@@ -0,0 +1,127 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package f64
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrCompacted is returned by Versioned.At for revisions that have been
+	// compacted away.
+	ErrCompacted = errors.New("btree: revision has been compacted")
+	// ErrFutureRev is returned by Versioned.At for revisions that have not
+	// been written yet.
+	ErrFutureRev = errors.New("btree: revision is in the future")
+)
+
+// Versioned is a tree that keeps snapshots of its recent versions, each
+// identified by a revision number that grows by one with every update, in
+// the style of an etcd keyspace.  Snapshots share their nodes copy-on-write,
+// so each one costs about as much memory as the nodes its update touched.
+//
+// A Versioned is safe for concurrent use by multiple goroutines.
+type Versioned struct {
+	mu       sync.RWMutex
+	tree     *BTree
+	keep     int
+	versions []*ImmutableBTree // oldest first, versions[i] is revision first+i
+	first    int64
+}
+
+// NewVersioned creates an empty versioned tree with the given degree, at
+// revision 0.  It keeps the latest keep revisions, dropping older ones as new
+// ones are written; keep <= 0 keeps every revision until Compact is called.
+func NewVersioned(degree, keep int) *Versioned {
+	v := &Versioned{tree: New(degree), keep: keep}
+	v.versions = append(v.versions, v.tree.Freeze())
+	return v
+}
+
+// Update calls fn with the tree, to which it may make any number of changes,
+// and then records the result as a new revision, which it returns.  Readers
+// of existing revisions never see the changes.  fn must not keep the tree
+// after returning.
+func (v *Versioned) Update(fn func(t *BTree)) int64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	fn(v.tree)
+	v.versions = append(v.versions, v.tree.Freeze())
+	if v.keep > 0 && len(v.versions) > v.keep {
+		v.drop(len(v.versions) - v.keep)
+	}
+	return v.rev()
+}
+
+// Rev returns the latest revision.
+func (v *Versioned) Rev() int64 {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.rev()
+}
+
+func (v *Versioned) rev() int64 {
+	return v.first + int64(len(v.versions)) - 1
+}
+
+// Current returns a snapshot of the latest revision.
+func (v *Versioned) Current() *ImmutableBTree {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.versions[len(v.versions)-1]
+}
+
+// At returns a snapshot of the tree as of the given revision.  It returns
+// ErrCompacted if that revision is no longer kept, and ErrFutureRev if it is
+// later than the latest one.
+func (v *Versioned) At(rev int64) (*ImmutableBTree, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if rev < v.first {
+		return nil, ErrCompacted
+	}
+	if rev > v.rev() {
+		return nil, ErrFutureRev
+	}
+	return v.versions[rev-v.first], nil
+}
+
+// Compact drops every revision before rev, making their nodes available to
+// the GC once no snapshot obtained from At references them.  The latest
+// revision is always kept.
+func (v *Versioned) Compact(rev int64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if rev > v.rev() {
+		rev = v.rev()
+	}
+	if rev > v.first {
+		v.drop(int(rev - v.first))
+	}
+}
+
+// drop removes the oldest k revisions.
+func (v *Versioned) drop(k int) {
+	for i := range v.versions[:k] {
+		v.versions[i] = nil
+	}
+	v.versions = v.versions[k:]
+	v.first += int64(k)
+}
@@ -0,0 +1,106 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package f64
+
+import "math"
+
+// QuantileWindow tracks quantiles of a value extracted from the items of a
+// Window, such as the latency of the requests of the last minute.  The items
+// are also kept ordered by value, as in a DualTree, and the order follows the
+// window as items are appended and evicted.
+//
+// Each evicted item is also deleted from the value order, which takes
+// O(log n) plus a scan over the items sharing its value, on top of the
+// eviction from the window itself.
+type QuantileWindow struct {
+	window *Window
+	values *DualTree
+}
+
+// NewQuantileWindow creates a QuantileWindow over w, ordering its items by
+// the value extracted by value.  Items already in w are included.  From then
+// on, items must be added through the QuantileWindow's Append; writing to w
+// directly would leave the value order out of sync.
+func NewQuantileWindow(w *Window, value IndexFunc) *QuantileWindow {
+	d := &DualTree{byKey: w.BTree, byValue: NewMulti(w.degree), value: value}
+	w.walk(func(i *Item) bool {
+		d.byValue.ReplaceOrInsert(&Item{Key: value(i), Payload: i})
+		return true
+	})
+	w.evicted = d.unindex
+	return &QuantileWindow{window: w, values: d}
+}
+
+// Window returns the window, for reading.
+func (w *QuantileWindow) Window() *Window {
+	return w.window
+}
+
+// Len returns the number of items in the window.
+func (w *QuantileWindow) Len() int {
+	return w.window.Len()
+}
+
+// Append adds the given item to the window as Window.Append does, returning
+// the number of items evicted.
+func (w *QuantileWindow) Append(item *Item) int {
+	w.values.ReplaceOrInsert(item)
+	return w.window.evict()
+}
+
+// Quantile returns the item at the q-quantile of the window by value, for q
+// between 0 and 1, using the nearest-rank method: the item whose value is the
+// smallest not exceeded by a q share of the items.  Quantile(0.5) returns the
+// median, and Quantile(0.99) the 99th percentile.  It returns nil if the
+// window is empty.
+//
+// The tree does not keep subtree sizes, so finding the item counts the items
+// of the nodes left of it, which takes O(n/degree).
+func (w *QuantileWindow) Quantile(q float64) *Item {
+	n := w.values.byValue.Len()
+	if n == 0 {
+		return nil
+	}
+	rank := int(math.Ceil(q*float64(n))) - 1
+	if rank < 0 {
+		rank = 0
+	} else if rank >= n {
+		rank = n - 1
+	}
+	return w.values.byValue.root.nth(rank).Payload.(*Item)
+}
+
+// nth returns the item at index i in ascending order within the subtree
+// rooted at n.
+func (n *node) nth(i int) *Item {
+	if len(n.children) == 0 {
+		return n.items[i]
+	}
+	for j, c := range n.children {
+		size := c.size()
+		if i < size {
+			return c.nth(i)
+		}
+		if i == size {
+			return n.items[j]
+		}
+		i -= size + 1
+	}
+	return nil
+}
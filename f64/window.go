@@ -0,0 +1,96 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package f64
+
+// Window is a BTree holding a sliding window over a stream of items whose keys
+// mostly increase, such as timestamps.  Each call to Append adds an item and
+// evicts the items that fell out of the window from the left edge of the
+// tree.
+//
+// Evicted items are cut off with DeleteRange, and whether anything has to go
+// is decided from the cached minimum, so an Append that evicts nothing costs
+// no more than the insert itself.  An Append that evicts k items also splits
+// the tree at the left edge, so it takes O(log n + k), not amortized O(1).
+// Items added through the embedded BTree's own methods are evicted by the
+// next Append like any other.
+type Window struct {
+	*BTree
+	limit  int
+	cutoff func(newest *Item) *Item
+	// evicted, if set, is called for every item evicted, before it is
+	// removed from the tree.
+	evicted func(*Item)
+}
+
+// NewCountWindow creates a new, empty Window with the given degree that keeps
+// the n greatest items.
+func NewCountWindow(degree, n int) *Window {
+	return &Window{BTree: New(degree), limit: n}
+}
+
+// NewKeyWindow creates a new, empty Window with the given degree that keeps
+// the items not less than cutoff(newest), where newest is the greatest item in
+// the tree.  A nil cutoff keeps everything.
+//
+// For example, a window over the last minute of a stream keyed by Unix time
+// would use a cutoff returning an item keyed newest.Key-60.
+func NewKeyWindow(degree int, cutoff func(newest *Item) *Item) *Window {
+	return &Window{BTree: New(degree), cutoff: cutoff}
+}
+
+// Append adds the given item to the window as ReplaceOrInsert does, then
+// evicts the items outside it, returning the number of items evicted.  An
+// item older than the window is accepted and immediately evicted again.
+func (w *Window) Append(item *Item) int {
+	w.ReplaceOrInsert(item)
+	return w.evict()
+}
+
+// evict removes the items outside the window.
+func (w *Window) evict() int {
+	if w.cutoff == nil {
+		excess := w.Len() - w.limit
+		if excess <= 0 {
+			return 0
+		}
+		out := w.DeleteMinN(excess)
+		for _, item := range out {
+			if w.evicted != nil {
+				w.evicted(item)
+			}
+			// DeleteMinN hands the items back, but the window drops them,
+			// as DeleteRange does below.
+			if w.itemPool != nil {
+				w.itemPool.Release(item)
+			}
+		}
+		return len(out)
+	}
+	c := w.cutoff(w.Max())
+	if c == nil || !w.Min().Less(c) {
+		return 0
+	}
+	if w.evicted != nil {
+		w.root.iterate(ascend, nil, c, false, false, func(i *Item) bool {
+			w.evicted(i)
+			return true
+		})
+	}
+	return w.DeleteRange(nil, c)
+}
@@ -1,11 +1,12 @@
 #!/usr/bin/env bash
 
-mkdir -p ./ui32 ./i32 ./ui64 ./i64 ./f32 ./f64 ./str
+types="i32:int32 i64:int64 ui32:uint32 ui64:uint64 f32:float32 f64:float64 str:string"
 
-cat ./base/btree.go | genny -pkg="i32" gen "KeyType=int32" > ./i32/btree.go
-cat ./base/btree.go | genny -pkg="i64" gen "KeyType=int64" > ./i64/btree.go
-cat ./base/btree.go | genny -pkg="ui32" gen "KeyType=uint32" > ./ui32/btree.go
-cat ./base/btree.go | genny -pkg="ui64" gen "KeyType=uint64" > ./ui64/btree.go
-cat ./base/btree.go | genny -pkg="f32" gen "KeyType=float32" > ./f32/btree.go
-cat ./base/btree.go | genny -pkg="f64" gen "KeyType=float64" > ./f64/btree.go
-cat ./base/btree.go | genny -pkg="str" gen "KeyType=string" > ./str/btree.go
\ No newline at end of file
+for t in $types; do
+	pkg=${t%%:*}
+	mkdir -p ./$pkg
+	for f in ./base/*.go; do
+		case $f in *_test.go) continue ;; esac
+		cat $f | genny -pkg="$pkg" gen "KeyType=${t#*:}" > ./$pkg/$(basename $f)
+	done
+done
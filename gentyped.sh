@@ -0,0 +1,35 @@
+#!/usr/bin/env bash
+
+# Generates a typed map wrapper from typed/typed.go to standard output.
+#
+# Usage: ./gentyped.sh <key package> <value type> <output package>
+#
+# The key package is one of the generated packages below.  For example,
+#
+#	./gentyped.sh i64 User models > models/user_tree.go
+#
+# generates a UserTree in package models mapping int64 keys to User values.
+
+types="i32:int32 i64:int64 ui32:uint32 ui64:uint64 f32:float32 f64:float64 str:string"
+
+if [ $# -ne 3 ]; then
+	echo "usage: $0 <key package> <value type> <output package>" >&2
+	exit 2
+fi
+
+keytype=
+for t in $types; do
+	if [ "${t%%:*}" = "$1" ]; then
+		keytype=${t#*:}
+	fi
+done
+if [ -z "$keytype" ]; then
+	echo "$0: unknown key package $1" >&2
+	exit 2
+fi
+
+dir=$(dirname "$0")
+sed -e "s#github.com/Rikanishu/btree/base#github.com/Rikanishu/btree/$1#" \
+	-e "s/base\.KeyType/$keytype/g" \
+	-e "s/base\./$1./g" \
+	"$dir/typed/typed.go" | genny -pkg="$3" gen "ValueType=$2"
@@ -0,0 +1,133 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i32
+
+// Builder builds a tree from items appended in ascending order, such as a
+// sorted stream read from disk, without ever holding them all in a slice as
+// InsertBatch needs to.  Nodes are filled left to right and never searched,
+// and all nodes but the last two of each level end up full.
+type Builder struct {
+	tree *BTree
+	// spine holds the open node of every level, leaves first.  The children
+	// of an open node are its finished ones, each followed by an item; the
+	// open node below is its last child, attached by Finish.
+	spine []*node
+	last  *Item
+}
+
+// NewBuilder returns a Builder for a tree with the given degree.
+func NewBuilder(degree int) *Builder {
+	return &Builder{tree: New(degree)}
+}
+
+// Append adds item to the tree being built.  Items must be appended in
+// strictly ascending order; Append panics otherwise.
+func (b *Builder) Append(item *Item) {
+	if item == nil {
+		panic("nil item being added to BTree")
+	}
+	if b.last != nil && b.outOfOrder(item) {
+		panic("btree: Builder items appended out of order")
+	}
+	b.last = item
+	b.tree.length++
+	b.push(0, item, nil)
+}
+
+// outOfOrder reports whether item may not follow the last item appended.
+// Builders filling a tree that holds duplicates, as UnmarshalBinary does, take
+// equal items in a row.
+func (b *Builder) outOfOrder(item *Item) bool {
+	if b.tree.multi {
+		return item.Less(b.last)
+	}
+	return !b.last.Less(item)
+}
+
+// push adds item to the open node of the given level, preceded by child, the
+// finished node of the level below, if the level is not the leaves.  A full
+// node is finished, and item moves up to separate it from the next.
+func (b *Builder) push(level int, item *Item, child *node) {
+	if level == len(b.spine) {
+		b.spine = append(b.spine, b.tree.cow.newNode())
+	}
+	n := b.spine[level]
+	if len(n.items) == b.tree.maxItems() {
+		if child != nil {
+			n.children = append(n.children, child)
+		}
+		b.spine[level] = b.tree.cow.newNode()
+		b.push(level+1, item, n)
+		return
+	}
+	n.items = append(n.items, item)
+	if child != nil {
+		n.children = append(n.children, child)
+	}
+}
+
+// Finish returns the tree holding all items appended.  The Builder must not
+// be used afterwards.
+func (b *Builder) Finish() *BTree {
+	t, spine := b.tree, b.spine
+	b.tree, b.spine, b.last = nil, nil, nil
+	if t.length == 0 {
+		return t
+	}
+	for k := 0; k < len(spine)-1; k++ {
+		spine[k+1].children = append(spine[k+1].children, spine[k])
+	}
+	// Fixing the spine top-down leaves every parent with items enough to
+	// have a left sibling for the node below.
+	for k := len(spine) - 2; k >= 0; k-- {
+		if len(spine[k].items) < t.minItems() {
+			spine[k+1].rebalanceLast()
+		}
+	}
+	t.root = spine[len(spine)-1]
+	t.resetBounds()
+	return t
+}
+
+// rebalanceLast spreads the items and children of the last two children of
+// n evenly over both.  The last child may be short of items, but the two hold
+// enough for both as long as the other is full.
+func (n *node) rebalanceLast() {
+	i := len(n.items) - 1
+	left, right := n.children[i], n.children[i+1]
+	all := make(items, 0, len(left.items)+1+len(right.items))
+	all = append(append(append(all, left.items...), n.items[i]), right.items...)
+	var kids children
+	if len(left.children) > 0 {
+		kids = make(children, 0, len(left.children)+len(right.children))
+		kids = append(append(kids, left.children...), right.children...)
+	}
+	l := (len(all) - 1) / 2
+	left.items.truncate(0)
+	left.items = append(left.items, all[:l]...)
+	n.items[i] = all[l]
+	right.items.truncate(0)
+	right.items = append(right.items, all[l+1:]...)
+	if kids != nil {
+		left.children.truncate(0)
+		left.children = append(left.children, kids[:l+1]...)
+		right.children.truncate(0)
+		right.children = append(right.children, kids[l+1:]...)
+	}
+}
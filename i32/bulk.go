@@ -0,0 +1,279 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i32
+
+import "sort"
+
+// batchRebuildRatio is how many times larger than a batch the tree must be
+// for InsertBatch to insert the batch item by item rather than rebuild the
+// tree with it.
+const batchRebuildRatio = 16
+
+// build returns a subtree of height h holding the given sorted items, with
+// nodes packed as full as the B-Tree invariants allow.  The root gets at least
+// minChildren children unless it is a leaf.
+//
+// A child subtree of height h-1 plus the item separating it from the next
+// holds at most (2*degree)^h items, which gives the fewest children that can
+// take all the items; the items are then spread evenly over those children.
+// As long as the items fit a subtree of height h without being too few for
+// it, so does every child.
+func (c *copyOnWriteContext) build(items []*Item, h, minChildren, degree int) *node {
+	n := c.newNode()
+	if h == 0 {
+		n.items = append(n.items, items...)
+		return n
+	}
+	span := 1
+	for i := 0; i < h; i++ {
+		span *= 2 * degree
+	}
+	k := (len(items) + span) / span
+	if k < minChildren {
+		k = minChildren
+	}
+	per, extra := (len(items)-(k-1))/k, (len(items)-(k-1))%k
+	start := 0
+	for i := 0; i < k; i++ {
+		size := per
+		if i < extra {
+			size++
+		}
+		n.children = append(n.children, c.build(items[start:start+size], h-1, degree, degree))
+		start += size
+		if i < k-1 {
+			n.items = append(n.items, items[start])
+			start++
+		}
+	}
+	return n
+}
+
+// buildRoot returns the root of a new subtree holding the given sorted
+// items, or nil if there are none.
+func (c *copyOnWriteContext) buildRoot(items []*Item, degree int) *node {
+	if len(items) == 0 {
+		return nil
+	}
+	h, capacity := 0, 2*degree
+	for capacity-1 < len(items) {
+		h++
+		capacity *= 2 * degree
+	}
+	return c.build(items, h, 2, degree)
+}
+
+// rebuild replaces the contents of the tree with the given sorted items.
+func (t *BTree) rebuild(items []*Item) {
+	if t.root != nil {
+		t.root.reset(t.cow)
+	}
+	t.root = t.cow.buildRoot(items, t.degree)
+	t.length = len(items)
+	t.resetBounds()
+}
+
+// batchItem is an item of a batch along with its position in the batch.
+type batchItem struct {
+	item *Item
+	seq  int
+}
+
+// batch sorts items by key, then by position, which is faster than a stable
+// sort of the items themselves.
+type batch []batchItem
+
+func (b batch) Len() int      { return len(b) }
+func (b batch) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b batch) Less(i, j int) bool {
+	if b[i].item.Less(b[j].item) {
+		return true
+	}
+	return !b[j].item.Less(b[i].item) && b[i].seq < b[j].seq
+}
+
+// sortBatch returns a sorted copy of items.  Unless the tree holds multiple
+// equal items, only the last of several equal items is kept, as if they had
+// been inserted in order.
+func (t *BTree) sortBatch(items []*Item) []*Item {
+	b := make(batch, len(items))
+	for i, item := range items {
+		b[i] = batchItem{item, i}
+	}
+	sort.Sort(b)
+	out := make([]*Item, 0, len(b))
+	for i, bi := range b {
+		if !t.multi && i+1 < len(b) && !bi.item.Less(b[i+1].item) {
+			continue
+		}
+		out = append(out, bi.item)
+	}
+	return out
+}
+
+// InsertBatch adds all the given items to the tree, as if by calling
+// ReplaceOrInsert for each of them in order, and returns the number of items
+// that were added rather than replacing an equal item.
+//
+// The batch is sorted once.  Unless the tree is much larger than the batch,
+// the batch and the items of the tree are then merged in a single ordered
+// pass and the tree is rebuilt from the result with densely packed nodes,
+// which takes O(n + m) instead of O(m log n) for n items in the tree and m in
+// the batch.  Small batches are inserted item by item in key order.
+//
+// nil items cannot be added to the tree (will panic).
+func (t *BTree) InsertBatch(batch []*Item) int {
+	for _, item := range batch {
+		if item == nil {
+			panic("nil item being added to BTree")
+		}
+	}
+	sorted := t.sortBatch(batch)
+	before := t.length
+	if len(sorted)*batchRebuildRatio < t.length {
+		for _, item := range sorted {
+			t.ReplaceOrInsert(item)
+		}
+		return t.length - before
+	}
+	merged := make([]*Item, 0, t.length+len(sorted))
+	t.walk(func(item *Item) bool {
+		for len(sorted) > 0 && sorted[0].Less(item) {
+			merged = append(merged, sorted[0])
+			sorted = sorted[1:]
+		}
+		if !t.multi && len(sorted) > 0 && !item.Less(sorted[0]) {
+			merged = append(merged, sorted[0])
+			sorted = sorted[1:]
+			return true
+		}
+		merged = append(merged, item)
+		return true
+	})
+	merged = append(merged, sorted...)
+	t.rebuild(merged)
+	return t.length - before
+}
+
+// DeleteBatch removes an item equal to each of the given items from the tree,
+// as if by calling Delete for each of them, and returns the number of items
+// removed.
+//
+// Like InsertBatch, the targets are sorted once and, unless the tree is much
+// larger than the batch, removed in a single ordered pass that rebuilds the
+// tree instead of rebalancing it after every removal.
+func (t *BTree) DeleteBatch(batch []*Item) int {
+	sorted := t.sortBatch(batch)
+	before := t.length
+	if len(sorted)*batchRebuildRatio < t.length {
+		for _, item := range sorted {
+			t.Delete(item)
+		}
+		return before - t.length
+	}
+	kept := make([]*Item, 0, t.length)
+	var removed []*Item
+	t.walk(func(item *Item) bool {
+		for len(sorted) > 0 && sorted[0].Less(item) {
+			sorted = sorted[1:]
+		}
+		if len(sorted) > 0 && !item.Less(sorted[0]) {
+			sorted = sorted[1:]
+			if t.itemPool != nil {
+				removed = append(removed, item)
+			}
+			return true
+		}
+		kept = append(kept, item)
+		return true
+	})
+	if len(kept) < before {
+		t.rebuild(kept)
+	}
+	for _, item := range removed {
+		t.itemPool.Release(item)
+	}
+	return before - t.length
+}
+
+// Reserve prepares the tree to grow to n items by allocating the nodes that
+// will take them up front and adding them to its free list, so that loading
+// the items afterwards allocates next to nothing.  As with NewWithArena, the
+// nodes and the backing arrays of their slices come from a few large
+// allocations, sized for full nodes.
+//
+// The number of nodes is estimated for items inserted in order, which leaves
+// every node with the minimum number of items; random insertion needs fewer.
+// Nodes already in the free list count towards the estimate, and the free
+// list grows as needed to hold the rest.  Reserve does nothing for trees
+// created by NewWithPool.
+func (t *BTree) Reserve(n int) {
+	t.cow.freelist.reserve(n/t.minItems()+1, t.maxItems())
+}
+
+// reserve tops the list up to count nodes with room for maxItems items each.
+func (f *FreeList) reserve(count, maxItems int) {
+	if f.pool != nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	count -= len(f.freelist)
+	if count <= 0 {
+		return
+	}
+	if len(f.freelist)+count > cap(f.freelist) {
+		freelist := make([]*node, len(f.freelist), len(f.freelist)+count)
+		copy(freelist, f.freelist)
+		f.freelist = freelist
+	}
+	a := arena{slabSize: count, maxItems: maxItems}
+	for i := 0; i < count; i++ {
+		f.freelist = append(f.freelist, a.newNode())
+	}
+	if len(f.freelist) > f.highWater {
+		f.highWater = len(f.freelist)
+	}
+}
+
+// ShrinkToFit repacks the tree into as few nodes as its degree allows, as
+// InsertBatch does when it rebuilds the tree, and returns the number of bytes
+// by which Stats.Bytes dropped.  This is worth doing after deleting a large
+// share of the items, which leaves many nodes barely above the minimum.
+//
+// The old nodes go to the free list as far as it has room.  Nodes shared with
+// clones stay alive for as long as the clones use them.
+func (t *BTree) ShrinkToFit() int {
+	if t.root == nil {
+		return 0
+	}
+	before := t.Stats().Bytes
+	t.rebuild(t.collect())
+	return before - t.Stats().Bytes
+}
+
+// collect returns all items of the tree in key order.
+func (t *BTree) collect() []*Item {
+	all := make([]*Item, 0, t.length)
+	t.walk(func(i *Item) bool {
+		all = append(all, i)
+		return true
+	})
+	return all
+}
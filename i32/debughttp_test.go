@@ -0,0 +1,33 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i32
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestDebugHandlerEscapes guards the generated copy of DebugHandler against
+// rendering with text/template.
+func TestDebugHandlerEscapes(t *testing.T) {
+	tr := New(2)
+	tr.ReplaceOrInsert(&Item{Key: 1, Payload: "<script>"})
+	w := httptest.NewRecorder()
+	DebugHandler(tr.Clone, nil).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if body := w.Body.String(); !strings.Contains(body, "&lt;script&gt;") || strings.Contains(body, "<script>") {
+		t.Fatalf("payload not escaped:\n%s", body)
+	}
+}
@@ -0,0 +1,121 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i32
+
+// ImmutableBTree is a read-only snapshot of a BTree, as returned by Freeze.
+// It has the read methods of BTree but none of the write methods, so code
+// handed a snapshot cannot modify it by accident.
+//
+// Reads on a snapshot run the same code as reads on a BTree, and cost the
+// same: BTree reads never touch the copy-on-write bookkeeping, the freelist
+// or the item pool, so there is nothing a read-only tree could leave out.
+//
+// An ImmutableBTree is safe for concurrent use by multiple goroutines.
+type ImmutableBTree struct {
+	tree BTree
+}
+
+// Freeze returns a read-only snapshot of the tree.  Like Clone, this takes
+// O(1): the snapshot shares its nodes with t, and later writes to t copy the
+// nodes they touch rather than modify the snapshot.
+func (t *BTree) Freeze() *ImmutableBTree {
+	return &ImmutableBTree{tree: *t.Clone()}
+}
+
+// Thaw returns a new, writable tree holding the items of the snapshot.  As
+// with Clone, the two share their nodes copy-on-write, so this takes O(1).
+func (t *ImmutableBTree) Thaw() *BTree {
+	out := t.tree
+	out.cow = &copyOnWriteContext{freelist: t.tree.cow.freelist}
+	return &out
+}
+
+// Len returns the number of items in the snapshot.
+func (t *ImmutableBTree) Len() int { return t.tree.Len() }
+
+// Get is BTree.Get for the snapshot.
+func (t *ImmutableBTree) Get(key *Item) *Item { return t.tree.Get(key) }
+
+// Has is BTree.Has for the snapshot.
+func (t *ImmutableBTree) Has(key *Item) bool { return t.tree.Has(key) }
+
+// Min is BTree.Min for the snapshot.
+func (t *ImmutableBTree) Min() *Item { return t.tree.Min() }
+
+// Max is BTree.Max for the snapshot.
+func (t *ImmutableBTree) Max() *Item { return t.tree.Max() }
+
+// Floor is BTree.Floor for the snapshot.
+func (t *ImmutableBTree) Floor(item *Item) *Item { return t.tree.Floor(item) }
+
+// Ceiling is BTree.Ceiling for the snapshot.
+func (t *ImmutableBTree) Ceiling(item *Item) *Item { return t.tree.Ceiling(item) }
+
+// Next is BTree.Next for the snapshot.
+func (t *ImmutableBTree) Next(item *Item) *Item { return t.tree.Next(item) }
+
+// Prev is BTree.Prev for the snapshot.
+func (t *ImmutableBTree) Prev(item *Item) *Item { return t.tree.Prev(item) }
+
+// CountRange is BTree.CountRange for the snapshot.
+func (t *ImmutableBTree) CountRange(greaterOrEqual, lessThan *Item) int {
+	return t.tree.CountRange(greaterOrEqual, lessThan)
+}
+
+// Ascend is BTree.Ascend for the snapshot.
+func (t *ImmutableBTree) Ascend(iterator ItemIterator) { t.tree.Ascend(iterator) }
+
+// AscendRange is BTree.AscendRange for the snapshot.
+func (t *ImmutableBTree) AscendRange(greaterOrEqual, lessThan *Item, iterator ItemIterator) {
+	t.tree.AscendRange(greaterOrEqual, lessThan, iterator)
+}
+
+// AscendLessThan is BTree.AscendLessThan for the snapshot.
+func (t *ImmutableBTree) AscendLessThan(pivot *Item, iterator ItemIterator) {
+	t.tree.AscendLessThan(pivot, iterator)
+}
+
+// AscendGreaterOrEqual is BTree.AscendGreaterOrEqual for the snapshot.
+func (t *ImmutableBTree) AscendGreaterOrEqual(pivot *Item, iterator ItemIterator) {
+	t.tree.AscendGreaterOrEqual(pivot, iterator)
+}
+
+// AscendKeys is BTree.AscendKeys for the snapshot.
+func (t *ImmutableBTree) AscendKeys(iterator KeyIterator) { t.tree.AscendKeys(iterator) }
+
+// Descend is BTree.Descend for the snapshot.
+func (t *ImmutableBTree) Descend(iterator ItemIterator) { t.tree.Descend(iterator) }
+
+// DescendRange is BTree.DescendRange for the snapshot.
+func (t *ImmutableBTree) DescendRange(lessOrEqual, greaterThan *Item, iterator ItemIterator) {
+	t.tree.DescendRange(lessOrEqual, greaterThan, iterator)
+}
+
+// DescendLessOrEqual is BTree.DescendLessOrEqual for the snapshot.
+func (t *ImmutableBTree) DescendLessOrEqual(pivot *Item, iterator ItemIterator) {
+	t.tree.DescendLessOrEqual(pivot, iterator)
+}
+
+// DescendGreaterThan is BTree.DescendGreaterThan for the snapshot.
+func (t *ImmutableBTree) DescendGreaterThan(pivot *Item, iterator ItemIterator) {
+	t.tree.DescendGreaterThan(pivot, iterator)
+}
+
+// DescendKeys is BTree.DescendKeys for the snapshot.
+func (t *ImmutableBTree) DescendKeys(iterator KeyIterator) { t.tree.DescendKeys(iterator) }
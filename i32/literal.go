@@ -0,0 +1,116 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i32
+
+import "fmt"
+
+// KV is a key and the payload stored with it, for MustBuild.
+type KV struct {
+	Key   int32
+	Value interface{}
+}
+
+// MustBuild returns a new tree with the given degree holding the given pairs,
+// which must be in strictly increasing order of key.  The nodes are packed as
+// by InsertBatch, so the same pairs always give the same structure.  It panics
+// if the pairs are out of order, and is meant for tests and examples.
+func MustBuild(degree int, pairs ...KV) *BTree {
+	t := New(degree)
+	items := make([]*Item, len(pairs))
+	for i, p := range pairs {
+		items[i] = &Item{Key: p.Key, Payload: p.Value}
+		if i > 0 && !items[i-1].Less(items[i]) {
+			panic(fmt.Sprintf("btree: MustBuild: key %v does not sort after %v", p.Key, pairs[i-1].Key))
+		}
+	}
+	t.rebuild(items)
+	return t
+}
+
+// NodeLiteral describes a node of a tree for MustBuildNodes: its keys, and for
+// internal nodes one child per gap between them.
+type NodeLiteral struct {
+	Keys     []int32
+	Children []NodeLiteral
+}
+
+// MustBuildNodes returns a new tree with the given degree whose nodes have
+// exactly the shape described by root, for tests that need a particular
+// structure, such as one that once triggered a rebalancing bug.  Items are
+// created with nil payloads.  It panics if the result is not a valid B-Tree of
+// that degree.
+func MustBuildNodes(degree int, root NodeLiteral) *BTree {
+	t, err := buildNodes(degree, root)
+	if err != nil {
+		panic("btree: MustBuildNodes: " + err.Error())
+	}
+	return t
+}
+
+// buildNodes returns a new tree with the shape described by root, or an error
+// if that is not a valid B-Tree of the given degree.
+func buildNodes(degree int, root NodeLiteral) (*BTree, error) {
+	if len(root.Keys) > 0 || len(root.Children) > 0 {
+		if err := root.check(); err != nil {
+			return nil, err
+		}
+	}
+	t := New(degree)
+	t.root = root.build(t.cow, &t.length)
+	t.resetBounds()
+	if err := t.CheckInvariants(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// check returns an error if l or any node below it has no keys, or a number
+// of children other than none or one more than its keys.  Such literals have
+// no node to build into, so CheckInvariants could not catch them.
+func (l NodeLiteral) check() error {
+	if len(l.Keys) == 0 {
+		return fmt.Errorf("node has no keys")
+	}
+	if len(l.Children) > 0 && len(l.Children) != len(l.Keys)+1 {
+		return fmt.Errorf("node with %d keys has %d children", len(l.Keys), len(l.Children))
+	}
+	for _, child := range l.Children {
+		if err := child.check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// build returns a new subtree matching l, adding its items to count.  An
+// empty literal gives a nil subtree.
+func (l NodeLiteral) build(c *copyOnWriteContext, count *int) *node {
+	if len(l.Keys) == 0 && len(l.Children) == 0 {
+		return nil
+	}
+	n := c.newNode()
+	for _, k := range l.Keys {
+		n.items = append(n.items, &Item{Key: k})
+	}
+	*count += len(l.Keys)
+	for _, child := range l.Children {
+		n.children = append(n.children, child.build(c, count))
+	}
+	return n
+}
@@ -0,0 +1,137 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i32
+
+// RangeSet is a set of keys stored as disjoint half-open intervals [start,
+// end).  Adding an interval merges it with those it overlaps or touches, and
+// removing one splits the intervals it cuts through, so the set always holds
+// as few intervals as possible.
+//
+// Each interval is an item of a tree keyed by its start, with its end as
+// Payload.
+type RangeSet struct {
+	tree *BTree
+}
+
+// NewRangeSet creates a new, empty RangeSet whose tree has the given degree.
+func NewRangeSet(degree int) *RangeSet {
+	return &RangeSet{tree: New(degree)}
+}
+
+// intervalEnd returns the end of the interval stored in item.
+func intervalEnd(item *Item) int32 {
+	return item.Payload.(int32)
+}
+
+// Len returns the number of intervals in the set.
+func (s *RangeSet) Len() int {
+	return s.tree.Len()
+}
+
+// Add adds the keys in [start, end) to the set.  An empty interval, where end
+// is not greater than start, adds nothing.
+func (s *RangeSet) Add(start, end int32) {
+	if !(start < end) {
+		return
+	}
+	if prev := s.tree.Floor(&Item{Key: start}); prev != nil && !(intervalEnd(prev) < start) {
+		start = prev.Key
+		if end < intervalEnd(prev) {
+			end = intervalEnd(prev)
+		}
+	}
+	// The intervals starting within the new one or right at its end are
+	// merged into it.  They are disjoint and never touch, so only the last
+	// of them can extend it.
+	s.tree.AscendGreaterOrEqual(&Item{Key: start}, func(i *Item) bool {
+		if end < i.Key {
+			return false
+		}
+		if end < intervalEnd(i) {
+			end = intervalEnd(i)
+		}
+		return true
+	})
+	s.tree.DeleteRange(&Item{Key: start}, &Item{Key: end})
+	s.tree.ReplaceOrInsert(&Item{Key: start, Payload: end})
+}
+
+// Remove removes the keys in [start, end) from the set.  Intervals reaching
+// past either side are cut short, or split in two if they span the whole of
+// it.
+func (s *RangeSet) Remove(start, end int32) {
+	if !(start < end) {
+		return
+	}
+	var rest *Item
+	if last := s.tree.Prev(&Item{Key: end}); last != nil && end < intervalEnd(last) {
+		rest = &Item{Key: end, Payload: intervalEnd(last)}
+	}
+	if prev := s.tree.Prev(&Item{Key: start}); prev != nil && start < intervalEnd(prev) {
+		s.tree.ReplaceOrInsert(&Item{Key: prev.Key, Payload: start})
+	}
+	s.tree.DeleteRange(&Item{Key: start}, &Item{Key: end})
+	if rest != nil {
+		s.tree.ReplaceOrInsert(rest)
+	}
+}
+
+// Contains reports whether key is in the set.
+func (s *RangeSet) Contains(key int32) bool {
+	i := s.tree.Floor(&Item{Key: key})
+	return i != nil && key < intervalEnd(i)
+}
+
+// Ascend calls the iterator for every interval in the set in ascending order,
+// until iterator returns false.
+func (s *RangeSet) Ascend(iterator func(start, end int32) bool) {
+	s.tree.Ascend(func(i *Item) bool {
+		return iterator(i.Key, intervalEnd(i))
+	})
+}
+
+// Complement returns the keys in [start, end) missing from the set, as a new
+// RangeSet of the same degree.
+func (s *RangeSet) Complement(start, end int32) *RangeSet {
+	out := NewRangeSet(s.tree.degree)
+	if !(start < end) {
+		return out
+	}
+	from := &Item{Key: start}
+	if prev := s.tree.Floor(from); prev != nil {
+		from = prev
+	}
+	cur := start
+	s.tree.AscendGreaterOrEqual(from, func(i *Item) bool {
+		if !(i.Key < end) {
+			return false
+		}
+		if cur < i.Key {
+			out.tree.ReplaceOrInsert(&Item{Key: cur, Payload: i.Key})
+		}
+		if cur < intervalEnd(i) {
+			cur = intervalEnd(i)
+		}
+		return cur < end
+	})
+	if cur < end {
+		out.tree.ReplaceOrInsert(&Item{Key: cur, Payload: end})
+	}
+	return out
+}
@@ -0,0 +1,171 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i32
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+// ErrChecksum is returned by Restore when a chunk of a snapshot does not match
+// its checksum.
+var ErrChecksum = errors.New("btree: snapshot checksum mismatch")
+
+// DefaultSnapshotChunkSize is the chunk size Snapshot uses when given none.
+const DefaultSnapshotChunkSize = 64 << 10
+
+// Snapshot writes the tree to w in the format of MarshalBinary, except that
+// the header is followed by its CRC-32, and the items are split into chunks of
+// about chunkSize bytes, each preceded by its length and followed by its
+// CRC-32, and ended by an empty chunk.  Only one chunk is held in memory at a
+// time, however large the tree.
+//
+// The tree must not be modified while Snapshot runs.  To keep writing to it,
+// snapshot a Clone instead, which costs O(1) up front.
+func (t *BTree) Snapshot(w io.Writer, chunkSize int) error {
+	if chunkSize <= 0 {
+		chunkSize = DefaultSnapshotChunkSize
+	}
+	header := t.appendHeader(nil)
+	header = appendChecksum(header, header)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	codec := t.itemCodec()
+	chunk := make([]byte, 0, chunkSize+8)
+	var err error
+	t.walk(func(i *Item) bool {
+		if chunk, err = appendItem(chunk, codec, i); err != nil {
+			return false
+		}
+		if len(chunk) >= chunkSize {
+			chunk, err = writeChunk(w, chunk)
+		}
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(chunk) > 0 {
+		if chunk, err = writeChunk(w, chunk); err != nil {
+			return err
+		}
+	}
+	_, err = writeChunk(w, chunk)
+	return err
+}
+
+// writeChunk writes chunk to w framed by its length and checksum, returning it
+// emptied for reuse.
+func writeChunk(w io.Writer, chunk []byte) ([]byte, error) {
+	var frame [4]byte
+	binary.BigEndian.PutUint32(frame[:], uint32(len(chunk)))
+	if _, err := w.Write(frame[:]); err != nil {
+		return chunk, err
+	}
+	if _, err := w.Write(chunk); err != nil {
+		return chunk, err
+	}
+	_, err := w.Write(appendChecksum(frame[:0], chunk))
+	return chunk[:0], err
+}
+
+// appendChecksum appends the CRC-32 of data to dst.
+func appendChecksum(dst, data []byte) []byte {
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.ChecksumIEEE(data))
+	return append(dst, sum[:]...)
+}
+
+// readChecksum reads a CRC-32 from r and compares it with sum.
+func readChecksum(r io.Reader, sum uint32) error {
+	var frame [4]byte
+	if _, err := io.ReadFull(r, frame[:]); err != nil {
+		return unexpectedEOF(err)
+	}
+	if binary.BigEndian.Uint32(frame[:]) != sum {
+		return ErrChecksum
+	}
+	return nil
+}
+
+// Restore replaces the contents of the tree, as well as its degree and order,
+// with a snapshot read from r, as UnmarshalBinary does for the output of
+// MarshalBinary.  Reading stops at the end of the snapshot, so it may be
+// followed by other data.  If the snapshot is malformed, the tree is left
+// unchanged and ErrBadEncoding or ErrChecksum is returned.
+func (t *BTree) Restore(r io.Reader) error {
+	h := crc32.NewIEEE()
+	b, flags, count, err := readHeader(byteReader{io.TeeReader(r, h)})
+	if err != nil {
+		return err
+	}
+	if err := readChecksum(r, h.Sum32()); err != nil {
+		return err
+	}
+	codec := t.itemCodec()
+	var chunk bytes.Buffer
+	for {
+		var frame [4]byte
+		if _, err := io.ReadFull(r, frame[:]); err != nil {
+			return unexpectedEOF(err)
+		}
+		// The length is not checksummed, so the chunk is read without
+		// trusting it: the buffer only grows as data actually arrives.
+		size := int64(binary.BigEndian.Uint32(frame[:]))
+		chunk.Reset()
+		if _, err := chunk.ReadFrom(io.LimitReader(r, size)); err != nil {
+			return err
+		}
+		if int64(chunk.Len()) < size {
+			return ErrBadEncoding
+		}
+		if err := readChecksum(r, crc32.ChecksumIEEE(chunk.Bytes())); err != nil {
+			return err
+		}
+		if size == 0 {
+			return t.finishDecoding(b, flags, count)
+		}
+		if err := decodeItems(b, codec, chunk.Bytes()); err != nil {
+			return err
+		}
+	}
+}
+
+// unexpectedEOF reports a snapshot cut short as malformed.
+func unexpectedEOF(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrBadEncoding
+	}
+	return err
+}
+
+// byteReader reads single bytes from a Reader without reading ahead.
+type byteReader struct {
+	io.Reader
+}
+
+func (r byteReader) ReadByte() (byte, error) {
+	var b [1]byte
+	_, err := io.ReadFull(r.Reader, b[:])
+	return b[0], err
+}
@@ -0,0 +1,115 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i32
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// throttleMinSleep is the smallest delay a Throttle sleeps for; shorter ones
+// are accumulated into the next delay instead.
+const throttleMinSleep = time.Millisecond
+
+// throttleYieldEvery is how many items a Throttle lets through between calls
+// to runtime.Gosched when it has no reason to sleep.
+const throttleYieldEvery = 256
+
+// Throttle paces scans to a budget of items and/or bytes per second.  One
+// Throttle is meant to be shared by all scans made on behalf of one caller,
+// so that the budget holds across them; it is safe for concurrent use.
+//
+// A throttled scan sleeps whenever it gets ahead of its budget and otherwise
+// yields to the scheduler every few hundred items, so a long scan doesn't keep
+// point lookups running on other goroutines waiting.  Sleeping happens inside
+// the iteration, so the scanned tree must not be modified meanwhile, as with
+// any other iteration.
+type Throttle struct {
+	mu sync.Mutex
+	// itemCost and byteCost are in nanoseconds, kept as floats so that rates
+	// above one item or byte per nanosecond still cost something.
+	itemCost float64
+	byteCost float64
+	size     func(*Item) int
+	next     time.Time
+	// owed is the part of a nanosecond of cost not yet added to next.
+	owed float64
+	seen int
+}
+
+// NewThrottle creates a Throttle letting through at most itemsPerSecond items
+// and bytesPerSecond bytes per second, where a limit <= 0 is no limit.  size
+// reports the size of an item in bytes; it is only needed, and only called,
+// when bytesPerSecond is set.
+func NewThrottle(itemsPerSecond, bytesPerSecond int, size func(*Item) int) *Throttle {
+	th := &Throttle{}
+	if itemsPerSecond > 0 {
+		th.itemCost = float64(time.Second) / float64(itemsPerSecond)
+	}
+	if bytesPerSecond > 0 {
+		if size == nil {
+			panic("btree: NewThrottle needs a size function to limit bytes")
+		}
+		th.byteCost = float64(time.Second) / float64(bytesPerSecond)
+		th.size = size
+	}
+	return th
+}
+
+// Iterator wraps iterator so that it is paced by th.  The result can be passed
+// to any of the Ascend* and Descend* methods:
+//
+//	tr.AscendRange(lo, hi, th.Iterator(fn))
+func (th *Throttle) Iterator(iterator ItemIterator) ItemIterator {
+	return func(i *Item) bool {
+		th.wait(i)
+		return iterator(i)
+	}
+}
+
+// wait blocks until the budget allows item through.
+func (th *Throttle) wait(item *Item) {
+	cost := th.itemCost
+	if th.size != nil {
+		cost += float64(th.size(item)) * th.byteCost
+	}
+	delay, yield := th.reserve(time.Now(), cost)
+	if delay >= throttleMinSleep {
+		time.Sleep(delay)
+	} else if yield {
+		runtime.Gosched()
+	}
+}
+
+// reserve charges cost nanoseconds to the budget at time now, returning how
+// long to wait before letting the item through and whether to yield instead.
+func (th *Throttle) reserve(now time.Time, cost float64) (delay time.Duration, yield bool) {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	if th.next.Before(now) {
+		th.next = now
+	}
+	th.owed += cost
+	whole := time.Duration(th.owed)
+	th.owed -= float64(whole)
+	th.next = th.next.Add(whole)
+	th.seen++
+	return th.next.Sub(now), th.seen%throttleYieldEvery == 0
+}
@@ -0,0 +1,127 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i64
+
+// CachedTree is a BTree with a small LRU cache of recent Get results in front
+// of it, for read-heavy workloads where a few hot keys are looked up over and
+// over.  A hit costs a map lookup instead of a descent through the tree.
+// Misses are cached too, so repeated lookups of absent keys are just as cheap.
+//
+// The cache is dropped whenever the tree may have changed since it was filled,
+// which is told from the nodes written to rather than from the methods called,
+// so writes through any method of the embedded BTree, or to the tree directly,
+// are seen alike.
+//
+// Unlike a BTree, a CachedTree is not safe for concurrent reads, since Get
+// updates the cache.
+type CachedTree struct {
+	*BTree
+	entries map[int64]*cacheEntry
+	// head is the sentinel of the ring of entries, most recently used first.
+	head cacheEntry
+	size int
+	// version is the state of the tree the entries were looked up in.
+	version treeVersion
+}
+
+// cacheEntry is the cached result of a Get, linked into the LRU ring.
+type cacheEntry struct {
+	key        int64
+	item       *Item
+	prev, next *cacheEntry
+}
+
+// treeVersion identifies a state of a tree: every write to the tree changes
+// at least one of its fields.
+type treeVersion struct {
+	cow    *copyOnWriteContext
+	writes uint64
+	root   *node
+	length int
+}
+
+// version returns the current state of the tree.  Holding on to it keeps the
+// copy-on-write context alive, so its address cannot be reused by another.
+func (t *BTree) version() treeVersion {
+	return treeVersion{cow: t.cow, writes: t.cow.writes, root: t.root, length: t.length}
+}
+
+// NewCachedTree returns a CachedTree caching up to size results of Get on t.
+func NewCachedTree(t *BTree, size int) *CachedTree {
+	if size <= 0 {
+		panic("bad cache size")
+	}
+	c := &CachedTree{BTree: t, entries: make(map[int64]*cacheEntry, size), size: size}
+	c.purge()
+	return c
+}
+
+// Get looks for the key item in the tree, returning it.  It returns nil if
+// unable to find that item.
+func (c *CachedTree) Get(key *Item) *Item {
+	if v := c.BTree.version(); v != c.version {
+		c.purge()
+		c.version = v
+	}
+	if e, ok := c.entries[key.Key]; ok {
+		c.unlink(e)
+		c.pushFront(e)
+		return e.item
+	}
+	item := c.BTree.Get(key)
+	if key.Key != key.Key {
+		// NaN keys could never be found in the map again.
+		return item
+	}
+	var e *cacheEntry
+	if len(c.entries) < c.size {
+		e = &cacheEntry{}
+	} else {
+		// Reuse the least recently used entry.
+		e = c.head.prev
+		c.unlink(e)
+		delete(c.entries, e.key)
+	}
+	e.key, e.item = key.Key, item
+	c.entries[key.Key] = e
+	c.pushFront(e)
+	return item
+}
+
+// Has returns true if the given key is in the tree.
+func (c *CachedTree) Has(key *Item) bool {
+	return c.Get(key) != nil
+}
+
+// purge drops all cached results.
+func (c *CachedTree) purge() {
+	for k := range c.entries {
+		delete(c.entries, k)
+	}
+	c.head.prev, c.head.next = &c.head, &c.head
+}
+
+func (c *CachedTree) unlink(e *cacheEntry) {
+	e.prev.next, e.next.prev = e.next, e.prev
+}
+
+func (c *CachedTree) pushFront(e *cacheEntry) {
+	e.prev, e.next = &c.head, c.head.next
+	e.prev.next, e.next.prev = e, e
+}
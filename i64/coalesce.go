@@ -0,0 +1,173 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i64
+
+import "time"
+
+// Coalescer buffers writes to a tree in a map by key, so that repeated writes
+// to the same hot keys between flushes reach the tree only once.  Writes made
+// with Upsert are merged in the buffer, so that updates such as counter
+// increments coalesce too.  Flushes apply the buffered writes with DeleteBatch
+// and InsertBatch, and buffered merges with Upsert.
+//
+// Point lookups through Get see buffered writes without flushing.  Anything
+// else should go through Tree, which flushes first.  A Coalescer cannot front
+// trees created by NewMulti, whose equal items must all be kept.
+type Coalescer struct {
+	tree     *BTree
+	limit    int
+	maxDelay time.Duration
+	// oldest is the time the oldest buffered write was made.
+	oldest  time.Time
+	pending map[int64]pendingWrite
+}
+
+// pendingWrite is a buffered write to a key.  item is nil for a buffered
+// delete.  If merge is set, item is still to be merged into the item in the
+// tree, as by Upsert; otherwise it replaces it.
+type pendingWrite struct {
+	item  *Item
+	merge MergeFunc
+}
+
+// NewCoalescer returns a Coalescer buffering writes to t, which flushes
+// whenever limit distinct keys have buffered writes.  limit <= 0 leaves
+// flushing to SetMaxDelay and to calls to Flush and Tree.
+func NewCoalescer(t *BTree, limit int) *Coalescer {
+	return &Coalescer{tree: t, limit: limit, pending: make(map[int64]pendingWrite)}
+}
+
+// SetMaxDelay makes the Coalescer flush on the first write made once its
+// oldest buffered write is d old, or stops it doing so if d <= 0.  Like the
+// tree, a Coalescer is not safe for concurrent use, so it does not flush on
+// its own meanwhile; to bound the delay when writes stop, call Flush from a
+// time.Ticker loop under the lock that guards the Coalescer.
+func (c *Coalescer) SetMaxDelay(d time.Duration) {
+	c.maxDelay = d
+}
+
+// ReplaceOrInsert buffers adding the given item to the tree, replacing any
+// write to its key buffered before.
+//
+// nil cannot be added to the tree (will panic).
+func (c *Coalescer) ReplaceOrInsert(item *Item) {
+	if item == nil {
+		panic("nil item being added to BTree")
+	}
+	c.buffer(item.Key, pendingWrite{item: item})
+}
+
+// Upsert buffers adding the given item to the tree as BTree.Upsert does.  If
+// a write to its key is buffered already, the two are combined in the buffer:
+// a buffered item is replaced by merge(buffered, item), and a buffered delete
+// by item.  Otherwise the item is merged into the one in the tree by the
+// flush.
+//
+// So that a run of Upserts to a key reaches the tree as one, merge should be
+// associative, as adding up counter increments is; the combined item is
+// merged into the tree's with the merge func of the first of them.  A nil
+// merge makes this the same as ReplaceOrInsert.
+//
+// nil cannot be added to the tree (will panic).
+func (c *Coalescer) Upsert(item *Item, merge MergeFunc) {
+	if item == nil {
+		panic("nil item being added to BTree")
+	}
+	w, ok := c.pending[item.Key]
+	switch {
+	case !ok || merge == nil:
+		w = pendingWrite{item: item, merge: merge}
+	case w.item == nil:
+		w = pendingWrite{item: item}
+	default:
+		w.item = merged(w.item, item, merge)
+	}
+	c.buffer(item.Key, w)
+}
+
+// Delete buffers removing the item equal to key from the tree, replacing any
+// write to its key buffered before.
+func (c *Coalescer) Delete(key *Item) {
+	c.buffer(key.Key, pendingWrite{})
+}
+
+func (c *Coalescer) buffer(key int64, w pendingWrite) {
+	if len(c.pending) == 0 {
+		c.oldest = time.Now()
+	}
+	c.pending[key] = w
+	if c.limit > 0 && len(c.pending) >= c.limit ||
+		c.maxDelay > 0 && time.Since(c.oldest) >= c.maxDelay {
+		c.Flush()
+	}
+}
+
+// Get returns the item with the given key as it will be once buffered writes
+// are flushed, or nil if there will be none.  A buffered Upsert to the key,
+// whose result depends on the tree, is flushed first.
+func (c *Coalescer) Get(key *Item) *Item {
+	w, ok := c.pending[key.Key]
+	if !ok {
+		return c.tree.Get(key)
+	}
+	if w.merge == nil {
+		return w.item
+	}
+	delete(c.pending, key.Key)
+	c.tree.Upsert(w.item, w.merge)
+	return c.tree.Get(key)
+}
+
+// Pending returns the number of keys with buffered writes.
+func (c *Coalescer) Pending() int {
+	return len(c.pending)
+}
+
+// Flush applies all buffered writes to the tree.
+func (c *Coalescer) Flush() {
+	if len(c.pending) == 0 {
+		return
+	}
+	var inserts, deletes []*Item
+	for key, w := range c.pending {
+		switch {
+		case w.item == nil:
+			deletes = append(deletes, &Item{Key: key})
+		case w.merge != nil:
+			c.tree.Upsert(w.item, w.merge)
+		default:
+			inserts = append(inserts, w.item)
+		}
+		delete(c.pending, key)
+	}
+	if len(deletes) > 0 {
+		c.tree.DeleteBatch(deletes)
+	}
+	if len(inserts) > 0 {
+		c.tree.InsertBatch(inserts)
+	}
+}
+
+// Tree flushes the buffered writes and returns the tree, for reads beyond
+// Get.  Writes made to it directly bypass the buffer, and are overridden by
+// writes to the same keys buffered later.
+func (c *Coalescer) Tree() *BTree {
+	c.Flush()
+	return c.tree
+}
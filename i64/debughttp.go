@@ -0,0 +1,171 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i64
+
+import (
+	"encoding/json"
+	"fmt"
+	// Named, so that regenerating the typed packages cannot swap in
+	// text/template, which would serve keys and payloads unescaped.
+	htmltemplate "html/template"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// DefaultDebugPageSize is the number of items DebugHandler serves per
+	// page unless the request asks for another number.
+	DefaultDebugPageSize = 100
+	// MaxDebugPageSize caps the number of items DebugHandler serves per page.
+	MaxDebugPageSize = 1000
+)
+
+// DebugHandler returns an HTTP handler serving a read-only view of a tree's
+// items, a page at a time, for debugging live processes.  It is not
+// registered anywhere; mount it wherever the process serves its debug pages.
+//
+// The tree is not safe for concurrent use, so each request gets it from
+// snapshot, which would typically return a Clone taken under the lock that
+// guards the tree.  redact turns an item's payload into what is shown, which
+// is where secrets should be dropped; a nil redact shows payloads as they are.
+// Both keys and shown values are formatted with fmt.Sprint.
+//
+// The query parameters are:
+//
+//	from    first key to show; keys before it are skipped
+//	to      key to stop before
+//	limit   number of items per page, at most MaxDebugPageSize
+//	format  "json" for JSON, anything else for HTML
+//
+// String keys are taken from the query as they are, spaces included; other
+// keys are parsed with fmt.Sscan, as in LoadDump.  Each page links to the next
+// by the key it stopped at.
+func DebugHandler(snapshot func() *BTree, redact func(*Item) interface{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		from, err := parseDebugKey(q.Get("from"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("bad from key: %v", err), http.StatusBadRequest)
+			return
+		}
+		to, err := parseDebugKey(q.Get("to"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("bad to key: %v", err), http.StatusBadRequest)
+			return
+		}
+		limit := DefaultDebugPageSize
+		if s := q.Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				http.Error(w, fmt.Sprintf("bad limit %q", s), http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+		if limit > MaxDebugPageSize {
+			limit = MaxDebugPageSize
+		}
+		page := debugPage(snapshot(), from, to, limit, redact)
+		if q.Get("format") == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(page)
+			return
+		}
+		if page.Next != nil {
+			next := r.URL.Query()
+			next.Set("from", *page.Next)
+			page.NextURL = "?" + next.Encode()
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		debugTemplate.Execute(w, page)
+	})
+}
+
+// DebugPage is a page of items served by DebugHandler.
+type DebugPage struct {
+	// Len is the number of items in the whole tree.
+	Len   int         `json:"len"`
+	Items []DebugItem `json:"items"`
+	// Next is the key of the first item of the next page, or nil on the
+	// last page.
+	Next    *string `json:"next"`
+	NextURL string  `json:"-"`
+}
+
+// DebugItem is an item as served by DebugHandler.
+type DebugItem struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// parseDebugKey returns an item with the key in s, or nil if s is empty.
+func parseDebugKey(s string) (*Item, error) {
+	if s == "" {
+		return nil, nil
+	}
+	item := &Item{}
+	if p, ok := interface{}(&item.Key).(*string); ok {
+		// Sscan would stop at the first space.
+		*p = s
+		return item, nil
+	}
+	if _, err := fmt.Sscan(s, &item.Key); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// debugPage returns up to limit items of t from from on, stopping before to.
+func debugPage(t *BTree, from, to *Item, limit int, redact func(*Item) interface{}) *DebugPage {
+	page := &DebugPage{Len: t.Len(), Items: []DebugItem{}}
+	visit := func(i *Item) bool {
+		if len(page.Items) == limit {
+			next := fmt.Sprint(i.Key)
+			page.Next = &next
+			return false
+		}
+		value := i.Payload
+		if redact != nil {
+			value = redact(i)
+		}
+		page.Items = append(page.Items, DebugItem{Key: fmt.Sprint(i.Key), Value: fmt.Sprint(value)})
+		return true
+	}
+	switch {
+	case from == nil && to == nil:
+		t.Ascend(visit)
+	case from == nil:
+		t.AscendLessThan(to, visit)
+	case to == nil:
+		t.AscendGreaterOrEqual(from, visit)
+	default:
+		t.AscendRange(from, to, visit)
+	}
+	return page
+}
+
+var debugTemplate = htmltemplate.Must(htmltemplate.New("debug").Parse(`<!DOCTYPE html>
+<title>btree</title>
+<p>{{.Len}} items</p>
+<table>
+<tr><th>Key</th><th>Value</th></tr>
+{{range .Items}}<tr><td>{{.Key}}</td><td>{{.Value}}</td></tr>
+{{end}}</table>
+{{with .NextURL}}<p><a href="{{.}}">Next page</a></p>{{end}}
+`))
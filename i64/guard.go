@@ -0,0 +1,56 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i64
+
+import "fmt"
+
+// IteratorPanic is the value GuardIterator panics with when the iterator it
+// wraps panics.
+type IteratorPanic struct {
+	// Key is the key of the item the iterator was called with.
+	Key int64
+	// Value is the value the iterator panicked with.
+	Value interface{}
+}
+
+func (p *IteratorPanic) Error() string {
+	return fmt.Sprintf("btree: iterator panicked at key %v: %v", p.Key, p.Value)
+}
+
+// GuardIterator returns an ItemIterator that calls iter, and should iter
+// panic, calls cleanup (if not nil) and panics again with an *IteratorPanic
+// recording the key of the item being visited.  Wrappers that hold locks or
+// cursors over an iteration can release them in cleanup, while the caller
+// still sees the panic.
+//
+// Iteration itself never modifies the tree, so a tree is always left intact
+// by a panicking iterator; only state kept outside of it needs cleaning up.
+func GuardIterator(iter ItemIterator, cleanup func()) ItemIterator {
+	return func(i *Item) bool {
+		defer func() {
+			if r := recover(); r != nil {
+				if cleanup != nil {
+					cleanup()
+				}
+				panic(&IteratorPanic{Key: i.Key, Value: r})
+			}
+		}()
+		return iter(i)
+	}
+}
@@ -0,0 +1,127 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i64
+
+// NewMulti creates a new B-Tree with the given degree that allows multiple
+// equal items, such as the entries of a secondary index sharing one indexed
+// value.  Equal items are kept in the order they were inserted, and every
+// ordered operation visits them in that order.
+func NewMulti(degree int) *BTree {
+	t := New(degree)
+	t.multi = true
+	return t
+}
+
+// insertMulti inserts an item into the subtree rooted at this node after any
+// items equal to it, making sure no nodes in the subtree exceed maxItems items.
+func (n *node) insertMulti(item *Item, maxItems int) {
+	i := n.items.upperBound(item)
+	if len(n.children) == 0 {
+		n.items.insertAt(i, item)
+		return
+	}
+	if n.maybeSplitChild(i, maxItems) && !item.Less(n.items[i]) {
+		i++ // we want second split node
+	}
+	n.mutableChild(i).insertMulti(item, maxItems)
+}
+
+// GetAll returns all items equal to key, in insertion order for trees created
+// by NewMulti.  It returns nil if there are none.
+func (t *BTree) GetAll(key *Item) (out []*Item) {
+	if t.root == nil {
+		return nil
+	}
+	t.root.iterate(ascend, key, nil, true, false, func(i *Item) bool {
+		if key.Less(i) {
+			return false
+		}
+		out = append(out, i)
+		return true
+	})
+	return
+}
+
+// DeleteOne removes the first inserted of the items equal to key from the
+// tree, returning it.  If no such item exists, returns nil.
+func (t *BTree) DeleteOne(key *Item) *Item {
+	return t.deleteItem(key, removeFirst)
+}
+
+// DeleteAll removes all items equal to key from the tree, returning the
+// number of items removed.
+func (t *BTree) DeleteAll(key *Item) int {
+	removed := 0
+	for {
+		item := t.deleteItem(key, removeFirst)
+		if item == nil {
+			return removed
+		}
+		if t.itemPool != nil {
+			t.itemPool.Release(item)
+		}
+		removed++
+	}
+}
+
+// GetMatch returns the first inserted of the items equal to key for which
+// match returns true, or nil if there is none.  It tells apart equal items in
+// trees created by NewMulti by more than their key, such as a version or an
+// owner kept in the Payload.
+func (t *BTree) GetMatch(key *Item, match func(*Item) bool) (out *Item) {
+	if t.root == nil {
+		return nil
+	}
+	t.root.iterate(ascend, key, nil, true, false, func(i *Item) bool {
+		if key.Less(i) {
+			return false
+		}
+		if match(i) {
+			out = i
+			return false
+		}
+		return true
+	})
+	return
+}
+
+// DeleteMatch removes the item GetMatch returns from the tree, returning it.
+// If no such item exists, returns nil.
+func (t *BTree) DeleteMatch(key *Item, match func(*Item) bool) *Item {
+	item := t.GetMatch(key, match)
+	if item == nil {
+		return nil
+	}
+	return t.deleteItem(item, removeExact)
+}
+
+// holds reports whether the subtree rooted at n holds item itself, rather
+// than just an item equal to it.
+func (n *node) holds(item *Item) bool {
+	lo, hi := n.items.lowerBound(item), n.items.upperBound(item)
+	for j := lo; j <= hi; j++ {
+		if len(n.children) > 0 && n.children[j].holds(item) {
+			return true
+		}
+		if j < hi && n.items[j] == item {
+			return true
+		}
+	}
+	return false
+}
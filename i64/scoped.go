@@ -0,0 +1,198 @@
+// This file was automatically generated by genny.
+// Any changes will be lost if this file is regenerated.
+// see https://github.com/cheekybits/genny
+
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i64
+
+import "fmt"
+
+// ScopedTree is a view of the items of a tree within a range of keys, as
+// returned by BTree.Delegate.  Reads see only the items in the range, and
+// writes of items outside of it panic, so a component can be handed a slice
+// of the keyspace of a shared tree without being able to touch the rest.
+type ScopedTree struct {
+	tree   *BTree
+	lo, hi *Item
+}
+
+// Delegate returns a view of the items of t in the range [greaterOrEqual,
+// lessThan) of its order, where a nil bound leaves that side of the range
+// open.  The view reads and writes t itself, so changes through either show
+// in the other.
+func (t *BTree) Delegate(greaterOrEqual, lessThan *Item) *ScopedTree {
+	return &ScopedTree{tree: t, lo: greaterOrEqual, hi: lessThan}
+}
+
+// less reports whether a comes before b in the order of the tree.
+func (t *BTree) less(a, b *Item) bool {
+	if t.reverse {
+		return b.Less(a)
+	}
+	return a.Less(b)
+}
+
+// Contains reports whether the key of item is within the range of the view.
+func (s *ScopedTree) Contains(item *Item) bool {
+	return (s.lo == nil || !s.tree.less(item, s.lo)) && (s.hi == nil || s.tree.less(item, s.hi))
+}
+
+func (s *ScopedTree) check(item *Item) {
+	if item == nil {
+		panic("nil item being added to BTree")
+	}
+	if !s.Contains(item) {
+		panic(fmt.Sprintf("btree: key %v is out of the scope of the tree", item.Key))
+	}
+}
+
+// clip narrows the range [greaterOrEqual, lessThan) to the range of the view.
+func (s *ScopedTree) clip(greaterOrEqual, lessThan *Item) (*Item, *Item) {
+	if greaterOrEqual == nil || (s.lo != nil && s.tree.less(greaterOrEqual, s.lo)) {
+		greaterOrEqual = s.lo
+	}
+	if lessThan == nil || (s.hi != nil && s.tree.less(s.hi, lessThan)) {
+		lessThan = s.hi
+	}
+	return greaterOrEqual, lessThan
+}
+
+// Len returns the number of items in the view.
+func (s *ScopedTree) Len() int {
+	return s.tree.CountRange(s.lo, s.hi)
+}
+
+// Get looks for the key item in the view, returning it.  It returns nil if
+// unable to find that item.
+func (s *ScopedTree) Get(key *Item) *Item {
+	if !s.Contains(key) {
+		return nil
+	}
+	return s.tree.Get(key)
+}
+
+// Has returns true if the given key is in the view.
+func (s *ScopedTree) Has(key *Item) bool {
+	return s.Contains(key) && s.tree.Has(key)
+}
+
+// Min returns the first item of the view, or nil if it is empty.
+func (s *ScopedTree) Min() *Item {
+	var out *Item
+	if s.lo == nil {
+		out = s.tree.Min()
+	} else {
+		out = s.tree.Ceiling(s.lo)
+	}
+	if out == nil || !s.Contains(out) {
+		return nil
+	}
+	return out
+}
+
+// Max returns the last item of the view, or nil if it is empty.
+func (s *ScopedTree) Max() *Item {
+	var out *Item
+	if s.hi == nil {
+		out = s.tree.Max()
+	} else {
+		out = s.tree.Prev(s.hi)
+	}
+	if out == nil || !s.Contains(out) {
+		return nil
+	}
+	return out
+}
+
+// ReplaceOrInsert is BTree.ReplaceOrInsert for the view.  It panics if item
+// is out of the range of the view.
+func (s *ScopedTree) ReplaceOrInsert(item *Item) *Item {
+	s.check(item)
+	return s.tree.ReplaceOrInsert(item)
+}
+
+// Delete is BTree.Delete for the view.  Items out of its range are never
+// removed.
+func (s *ScopedTree) Delete(item *Item) *Item {
+	if !s.Contains(item) {
+		return nil
+	}
+	return s.tree.Delete(item)
+}
+
+// DeleteMin removes the first item of the view and returns it.
+func (s *ScopedTree) DeleteMin() *Item {
+	if first := s.Min(); first != nil {
+		return s.tree.Delete(first)
+	}
+	return nil
+}
+
+// DeleteMax removes the last item of the view and returns it.
+func (s *ScopedTree) DeleteMax() *Item {
+	if last := s.Max(); last != nil {
+		return s.tree.Delete(last)
+	}
+	return nil
+}
+
+// DeleteRange is BTree.DeleteRange for the part of the range within the
+// view.
+func (s *ScopedTree) DeleteRange(greaterOrEqual, lessThan *Item) int {
+	return s.tree.DeleteRange(s.clip(greaterOrEqual, lessThan))
+}
+
+// Clear removes every item of the view from the tree.
+func (s *ScopedTree) Clear() int {
+	return s.tree.DeleteRange(s.lo, s.hi)
+}
+
+// AscendRange is BTree.AscendRange for the part of the range within the view,
+// where a nil bound leaves that side of the range open.
+func (s *ScopedTree) AscendRange(greaterOrEqual, lessThan *Item, iterator ItemIterator) {
+	greaterOrEqual, lessThan = s.clip(greaterOrEqual, lessThan)
+	switch {
+	case greaterOrEqual == nil && lessThan == nil:
+		s.tree.Ascend(iterator)
+	case greaterOrEqual == nil:
+		s.tree.AscendLessThan(lessThan, iterator)
+	case lessThan == nil:
+		s.tree.AscendGreaterOrEqual(greaterOrEqual, iterator)
+	default:
+		s.tree.AscendRange(greaterOrEqual, lessThan, iterator)
+	}
+}
+
+// Ascend calls the iterator for every item of the view, in order, until
+// iterator returns false.
+func (s *ScopedTree) Ascend(iterator ItemIterator) {
+	s.AscendRange(nil, nil, iterator)
+}
+
+// Descend calls the iterator for every item of the view, in reverse order,
+// until iterator returns false.
+func (s *ScopedTree) Descend(iterator ItemIterator) {
+	last := s.Max()
+	if last == nil {
+		return
+	}
+	s.tree.DescendLessOrEqual(last, func(i *Item) bool {
+		if s.lo != nil && s.tree.less(i, s.lo) {
+			return false
+		}
+		return iterator(i)
+	})
+}
@@ -0,0 +1,76 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package str
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDebugHandlerSpaces(t *testing.T) {
+	keys := []string{"a", "a b", "a b c", "a c", "b", " b", "b x", "c  "}
+	tr := New(2)
+	for _, k := range keys {
+		tr.ReplaceOrInsert(&Item{Key: k})
+	}
+	h := DebugHandler(tr.Clone, nil)
+	get := func(q url.Values) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/?"+q.Encode(), nil))
+		return w
+	}
+
+	// Paging by the next key must visit every key once, in order.
+	var got []string
+	q := url.Values{"format": {"json"}, "limit": {"2"}, "to": {"c  "}}
+	for {
+		var page DebugPage
+		if err := json.NewDecoder(get(q).Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range page.Items {
+			got = append(got, item.Key)
+		}
+		if page.Next == nil {
+			break
+		}
+		q.Set("from", *page.Next)
+	}
+	want := []string{" b", "a", "a b", "a b c", "a c", "b", "b x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	body := get(url.Values{"from": {"a b"}, "limit": {"2"}}).Body.String()
+	if !strings.Contains(body, "from=a&#43;c") {
+		t.Fatalf("html page does not link to the next key:\n%s", body)
+	}
+}
+
+// TestDebugHandlerEscapes guards the generated copy of DebugHandler against
+// rendering with text/template.
+func TestDebugHandlerEscapes(t *testing.T) {
+	tr := New(2)
+	tr.ReplaceOrInsert(&Item{Key: "<script>", Payload: "<script>"})
+	w := httptest.NewRecorder()
+	DebugHandler(tr.Clone, nil).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if body := w.Body.String(); !strings.Contains(body, "&lt;script&gt;") || strings.Contains(body, "<script>") {
+		t.Fatalf("key not escaped:\n%s", body)
+	}
+}
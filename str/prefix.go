@@ -0,0 +1,60 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file is not generated: it only makes sense for string keys.
+
+package str
+
+// AscendPrefix calls the iterator for every item in the tree whose key starts
+// with prefix, in the order of the tree, until iterator returns false.  The
+// range is bounded by the least string greater than every key with the
+// prefix, so no items past it are visited.
+func (t *BTree) AscendPrefix(prefix string, iterator ItemIterator) {
+	end, ok := prefixEnd(prefix)
+	if !t.reverse {
+		if !ok {
+			t.AscendGreaterOrEqual(&Item{Key: prefix}, iterator)
+			return
+		}
+		t.AscendRange(&Item{Key: prefix}, &Item{Key: end}, iterator)
+		return
+	}
+	// Trees created by NewReverse ascend in descending key order, from the
+	// item before end down to the prefix itself.
+	if t.root == nil {
+		return
+	}
+	var start *Item
+	if ok {
+		start = &Item{Key: end}
+	}
+	t.root.iterate(descend, start, nil, false, false, func(i *Item) bool {
+		return i.Key >= prefix && iterator(i)
+	})
+}
+
+// prefixEnd returns the least string greater than every string starting with
+// prefix, which is prefix with its last byte below 0xff incremented and the
+// bytes after it dropped.  It returns false if there is no such string, as
+// when prefix is empty or made of 0xff bytes only.
+func prefixEnd(prefix string) (string, bool) {
+	b := []byte(prefix)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < 0xff {
+			b[i]++
+			return string(b[:i+1]), true
+		}
+	}
+	return "", false
+}
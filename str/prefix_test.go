@@ -0,0 +1,72 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package str
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAscendPrefix(t *testing.T) {
+	tr := New(2)
+	for _, k := range []string{"user", "user/", "user/a", "user/b/c", "user0", "usera", "users/x", "", "\xff", "\xff\xff", "\xff\xffa"} {
+		tr.ReplaceOrInsert(&Item{Key: k})
+	}
+	for _, test := range []struct {
+		prefix string
+		want   []string
+	}{
+		{"user/", []string{"user/", "user/a", "user/b/c"}},
+		{"user", []string{"user", "user/", "user/a", "user/b/c", "user0", "usera", "users/x"}},
+		{"users", []string{"users/x"}},
+		{"x", nil},
+		{"\xff\xff", []string{"\xff\xff", "\xff\xffa"}},
+		{"", []string{"", "user", "user/", "user/a", "user/b/c", "user0", "usera", "users/x", "\xff", "\xff\xff", "\xff\xffa"}},
+	} {
+		var got []string
+		tr.AscendPrefix(test.prefix, func(i *Item) bool {
+			got = append(got, i.Key)
+			return true
+		})
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("prefix %q: got %q, want %q", test.prefix, got, test.want)
+		}
+	}
+}
+
+func TestAscendPrefixReverse(t *testing.T) {
+	tr := NewReverse(2)
+	for _, k := range []string{"user", "user/", "user/a", "user/b/c", "user0", "usera", "\xff", "\xffa"} {
+		tr.ReplaceOrInsert(&Item{Key: k})
+	}
+	for _, test := range []struct {
+		prefix string
+		want   []string
+	}{
+		{"user/", []string{"user/b/c", "user/a", "user/"}},
+		{"user", []string{"usera", "user0", "user/b/c", "user/a", "user/", "user"}},
+		{"\xff", []string{"\xffa", "\xff"}},
+		{"x", nil},
+	} {
+		var got []string
+		tr.AscendPrefix(test.prefix, func(i *Item) bool {
+			got = append(got, i.Key)
+			return true
+		})
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("prefix %q: got %q, want %q", test.prefix, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package typed is the template for typed map wrappers around the trees,
+// generated into user code by gentyped.sh:
+//
+//	./gentyped.sh i64 User models > models/user_tree.go
+//
+// generates a UserTree in package models, mapping int64 keys to User values
+// through the i64 package.  Callers never see interface{} or type assertions,
+// and values are never boxed: each is kept in a typed field of its item, with
+// a single allocation per Put for the item itself.
+package typed
@@ -0,0 +1,97 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package typed
+
+import (
+	"github.com/Rikanishu/btree/base"
+	"github.com/cheekybits/genny/generic"
+)
+
+type ValueType generic.Type
+
+// ValueTypeTree is an ordered map from keys to ValueType values.
+type ValueTypeTree struct {
+	tree *base.BTree
+}
+
+// itemOfValueType is an item of a ValueTypeTree, which holds its value in a
+// typed field rather than in the Payload, so that storing a value never boxes
+// it.  The Payload points back to the itemOfValueType instead, which costs no
+// allocation, and reading the value back only asserts that pointer.
+type itemOfValueType struct {
+	base.Item
+	value ValueType
+}
+
+// NewValueTypeTree creates a new, empty ValueTypeTree with the given degree.
+func NewValueTypeTree(degree int) *ValueTypeTree {
+	return &ValueTypeTree{tree: base.New(degree)}
+}
+
+// Tree returns the underlying tree, for reads beyond those of the map.  The
+// payload of each of its items points to the item holding the value, so
+// items must only be added through the ValueTypeTree.
+func (t *ValueTypeTree) Tree() *base.BTree {
+	return t.tree
+}
+
+// Len returns the number of values in the map.
+func (t *ValueTypeTree) Len() int {
+	return t.tree.Len()
+}
+
+// Get returns the value stored under key.  The boolean reports whether there
+// was one.
+func (t *ValueTypeTree) Get(key base.KeyType) (ValueType, bool) {
+	return t.value(t.tree.Get(&base.Item{Key: key}))
+}
+
+// Put stores value under key, returning the value it replaces.  The boolean
+// reports whether there was one.
+func (t *ValueTypeTree) Put(key base.KeyType, value ValueType) (ValueType, bool) {
+	item := &itemOfValueType{Item: base.Item{Key: key}, value: value}
+	item.Payload = item
+	return t.value(t.tree.ReplaceOrInsert(&item.Item))
+}
+
+// Delete removes the value stored under key, returning it.  The boolean
+// reports whether there was one.
+func (t *ValueTypeTree) Delete(key base.KeyType) (ValueType, bool) {
+	return t.value(t.tree.Delete(&base.Item{Key: key}))
+}
+
+// Scan calls fn for every key and value with greaterOrEqual <= key < lessThan
+// in ascending order of key, until fn returns false.
+func (t *ValueTypeTree) Scan(greaterOrEqual, lessThan base.KeyType, fn func(key base.KeyType, value ValueType) bool) {
+	t.tree.AscendRange(&base.Item{Key: greaterOrEqual}, &base.Item{Key: lessThan}, func(i *base.Item) bool {
+		return fn(i.Key, i.Payload.(*itemOfValueType).value)
+	})
+}
+
+// Ascend calls fn for every key and value in ascending order of key, until
+// fn returns false.
+func (t *ValueTypeTree) Ascend(fn func(key base.KeyType, value ValueType) bool) {
+	t.tree.Ascend(func(i *base.Item) bool {
+		return fn(i.Key, i.Payload.(*itemOfValueType).value)
+	})
+}
+
+// value returns the value stored in item, which may be nil.
+func (t *ValueTypeTree) value(item *base.Item) (value ValueType, ok bool) {
+	if item == nil {
+		return value, false
+	}
+	return item.Payload.(*itemOfValueType).value, true
+}
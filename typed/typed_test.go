@@ -0,0 +1,55 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package typed
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Rikanishu/btree/base"
+)
+
+func TestValueTypeTree(t *testing.T) {
+	tr := NewValueTypeTree(2)
+	for i := 0; i < 10; i++ {
+		if _, ok := tr.Put(base.KeyType(i), i*10); ok {
+			t.Fatalf("put %d replaced a value", i)
+		}
+	}
+	if old, ok := tr.Put(5, "five"); !ok || old != 50 {
+		t.Fatalf("put 5 replaced %v, %v", old, ok)
+	}
+	if v, ok := tr.Get(5); !ok || v != "five" {
+		t.Fatalf("get 5: %v, %v", v, ok)
+	}
+	if v, ok := tr.Delete(3); !ok || v != 30 || tr.Len() != 9 {
+		t.Fatalf("delete 3: %v, %v, len %d", v, ok, tr.Len())
+	}
+	if v, ok := tr.Get(3); ok || v != nil {
+		t.Fatalf("get deleted 3: %v, %v", v, ok)
+	}
+	var keys []base.KeyType
+	tr.Scan(2, 6, func(key base.KeyType, value ValueType) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if want := []base.KeyType{2, 4, 5}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("scan: got %v, want %v", keys, want)
+	}
+	item := tr.Tree().Get(&base.Item{Key: 5})
+	if n := testing.AllocsPerRun(100, func() { tr.value(item) }); n != 0 {
+		t.Fatalf("%v allocations reading a value", n)
+	}
+}
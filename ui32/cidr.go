@@ -0,0 +1,176 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file is not generated: it only makes sense for uint32 keys, which hold
+// IPv4 addresses.
+
+package ui32
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+// ErrNotIPv4 is returned by CIDRTree.Insert for prefixes that are not IPv4.
+var ErrNotIPv4 = errors.New("btree: not an IPv4 prefix")
+
+// CIDRTree maps IPv4 network prefixes to values and finds the longest prefix
+// containing an address.  A range scan cannot do that on its own, since the
+// prefixes containing an address may start anywhere before it.
+//
+// Prefixes are keyed by their first address.  Prefixes sharing one, such as
+// 10.0.0.0/8 and 10.0.0.0/16, are kept in the same item, longest first.
+type CIDRTree struct {
+	tree   *BTree
+	length int
+}
+
+// cidrPrefix is one of the prefixes starting at the key of an item.
+type cidrPrefix struct {
+	bits  int
+	value interface{}
+}
+
+// NewCIDRTree creates a new, empty CIDRTree whose tree has the given degree.
+func NewCIDRTree(degree int) *CIDRTree {
+	return &CIDRTree{tree: New(degree)}
+}
+
+// Len returns the number of prefixes in the tree.
+func (c *CIDRTree) Len() int {
+	return c.length
+}
+
+// ipv4Prefix returns the first address and the length of prefix.
+func ipv4Prefix(prefix *net.IPNet) (uint32, int, bool) {
+	ip := prefix.IP.To4()
+	bits, size := prefix.Mask.Size()
+	if ip == nil || size != 32 {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint32(ip) & mask(bits), bits, true
+}
+
+// mask returns the netmask of a prefix of the given length.
+func mask(bits int) uint32 {
+	return ^uint32(0) << uint(32-bits)
+}
+
+// Insert maps prefix to value, replacing the value it had if it was already
+// in the tree.  Host bits set in prefix are ignored.
+func (c *CIDRTree) Insert(prefix *net.IPNet, value interface{}) error {
+	addr, bits, ok := ipv4Prefix(prefix)
+	if !ok {
+		return ErrNotIPv4
+	}
+	var prefixes []cidrPrefix
+	if item := c.tree.Get(&Item{Key: addr}); item != nil {
+		prefixes = item.Payload.([]cidrPrefix)
+	}
+	i := 0
+	for i < len(prefixes) && prefixes[i].bits > bits {
+		i++
+	}
+	out := make([]cidrPrefix, 0, len(prefixes)+1)
+	out = append(out, prefixes[:i]...)
+	out = append(out, cidrPrefix{bits: bits, value: value})
+	if i < len(prefixes) && prefixes[i].bits == bits {
+		i++
+	} else {
+		c.length++
+	}
+	out = append(out, prefixes[i:]...)
+	c.tree.ReplaceOrInsert(&Item{Key: addr, Payload: out})
+	return nil
+}
+
+// Delete removes prefix from the tree, reporting whether it was there.
+func (c *CIDRTree) Delete(prefix *net.IPNet) bool {
+	addr, bits, ok := ipv4Prefix(prefix)
+	if !ok {
+		return false
+	}
+	item := c.tree.Get(&Item{Key: addr})
+	if item == nil {
+		return false
+	}
+	prefixes := item.Payload.([]cidrPrefix)
+	for i, p := range prefixes {
+		if p.bits != bits {
+			continue
+		}
+		c.length--
+		if len(prefixes) == 1 {
+			c.tree.Delete(item)
+			return true
+		}
+		out := make([]cidrPrefix, 0, len(prefixes)-1)
+		out = append(append(out, prefixes[:i]...), prefixes[i+1:]...)
+		c.tree.ReplaceOrInsert(&Item{Key: addr, Payload: out})
+		return true
+	}
+	return false
+}
+
+// LongestPrefixMatch returns the longest prefix in the tree containing ip,
+// along with its value.  It returns false if no prefix contains ip, or ip is
+// not an IPv4 address.
+//
+// The prefixes containing ip are nested, so the longest starts last.  The
+// search takes the last key not after ip; if none of the prefixes starting
+// there contains ip, those that do must also contain that key, so they are
+// no longer than the bits ip shares with it, and the search goes on before
+// ip cut to those bits.  Each step shortens the prefix, so there are at most
+// 33 of them, and usually one.
+func (c *CIDRTree) LongestPrefixMatch(ip net.IP) (*net.IPNet, interface{}, bool) {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return nil, nil, false
+	}
+	addr := binary.BigEndian.Uint32(ip4)
+	limit := addr
+	for {
+		item := c.tree.Floor(&Item{Key: limit})
+		if item == nil {
+			return nil, nil, false
+		}
+		for _, p := range item.Payload.([]cidrPrefix) {
+			if addr&mask(p.bits) == item.Key {
+				return ipNet(item.Key, p.bits), p.value, true
+			}
+		}
+		if item.Key == 0 {
+			return nil, nil, false
+		}
+		shared := 0
+		for shared < 32 && (addr^item.Key)&(1<<uint(31-shared)) == 0 {
+			shared++
+		}
+		// Cutting ip to the shared bits gives an address no later than the
+		// key, so step back from it if they are the same.
+		next := addr & mask(shared)
+		if next == item.Key {
+			next--
+		}
+		limit = next
+	}
+}
+
+// ipNet returns the prefix of the given length starting at addr.
+func ipNet(addr uint32, bits int) *net.IPNet {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, addr)
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, 32)}
+}
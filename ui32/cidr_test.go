@@ -0,0 +1,102 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ui32
+
+import (
+	"math/rand"
+	"net"
+	"testing"
+)
+
+func TestLongestPrefixMatch(t *testing.T) {
+	c := NewCIDRTree(2)
+	for i, s := range []string{"0.0.0.0/0", "10.0.0.0/8", "10.0.0.0/16", "10.0.1.0/24", "10.1.2.3/32", "192.168.0.0/16", "192.168.128.0/17"} {
+		_, n, _ := net.ParseCIDR(s)
+		if err := c.Insert(n, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		ip, want string
+	}{
+		{"10.0.1.7", "10.0.1.0/24"},
+		{"10.0.2.7", "10.0.0.0/16"},
+		{"10.1.2.3", "10.1.2.3/32"},
+		{"10.1.2.4", "10.0.0.0/8"},
+		{"10.255.255.255", "10.0.0.0/8"},
+		{"11.0.0.0", "0.0.0.0/0"},
+		{"192.168.200.1", "192.168.128.0/17"},
+		{"192.168.1.1", "192.168.0.0/16"},
+		{"::ffff:10.0.1.1", "10.0.1.0/24"},
+	} {
+		got, _, ok := c.LongestPrefixMatch(net.ParseIP(test.ip))
+		if !ok || got.String() != test.want {
+			t.Errorf("%s: got %v, want %s", test.ip, got, test.want)
+		}
+	}
+	_, n, _ := net.ParseCIDR("0.0.0.0/0")
+	if !c.Delete(n) || c.Delete(n) || c.Len() != 6 {
+		t.Fatalf("delete: %d prefixes left", c.Len())
+	}
+	if got, _, ok := c.LongestPrefixMatch(net.ParseIP("11.0.0.0")); ok {
+		t.Fatalf("matched %v after deleting the default route", got)
+	}
+	if _, _, ok := c.LongestPrefixMatch(net.ParseIP("::1")); ok {
+		t.Fatal("matched an IPv6 address")
+	}
+	_, n6, _ := net.ParseCIDR("2001:db8::/32")
+	if err := c.Insert(n6, nil); err != ErrNotIPv4 {
+		t.Fatalf("inserting IPv6 prefix: got %v", err)
+	}
+}
+
+func TestLongestPrefixMatchRandom(t *testing.T) {
+	type prefix struct {
+		addr uint32
+		bits int
+	}
+	c := NewCIDRTree(3)
+	var prefixes []prefix
+	// Addresses are drawn from a small range so prefixes overlap a lot.
+	for i := 0; i < 300; i++ {
+		bits := rand.Intn(33)
+		p := prefix{rand.Uint32() & 0xff00ffff & mask(bits), bits}
+		prefixes = append(prefixes, p)
+		c.Insert(ipNet(p.addr, p.bits), p)
+	}
+	for i := 0; i < 2000; i++ {
+		addr := rand.Uint32() & 0xff00ffff
+		if i%2 == 0 {
+			addr = prefixes[rand.Intn(len(prefixes))].addr + uint32(rand.Intn(3)) - 1
+		}
+		want := -1
+		for _, p := range prefixes {
+			if addr&mask(p.bits) == p.addr && p.bits > want {
+				want = p.bits
+			}
+		}
+		ip := ipNet(addr, 32).IP
+		got, value, ok := c.LongestPrefixMatch(ip)
+		if !ok {
+			if want >= 0 {
+				t.Fatalf("%v: no match, want /%d", ip, want)
+			}
+			continue
+		}
+		if p := value.(prefix); ipNet(p.addr, p.bits).String() != got.String() || p.bits != want {
+			t.Fatalf("%v: got %v, want /%d", ip, got, want)
+		}
+	}
+}